@@ -2,17 +2,21 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"tubes2/craft"
 	"tubes2/searchalgo"
 	"tubes2/utilities"
+	"tubes2/verify"
 )
 
 type Recipe struct {
@@ -23,11 +27,29 @@ type Recipe struct {
 }
 
 type SearchRequest struct {
-	Algorithm       string   `json:"algorithm"`
-	TargetElement   string   `json:"targetElement"`
-	MultipleRecipes bool     `json:"multipleRecipes"`
-	RecipeCount     int      `json:"recipeCount"`
-	StartElements   []string `json:"startElements,omitempty"`
+	Algorithm       string                 `json:"algorithm"`
+	TargetElement   string                 `json:"targetElement"`
+	MultipleRecipes bool                   `json:"multipleRecipes"`
+	RecipeCount     int                    `json:"recipeCount"`
+	StartElements   []string               `json:"startElements,omitempty"`
+	Deterministic   *bool                  `json:"deterministic,omitempty"`
+	Seed            int64                  `json:"seed,omitempty"`
+	SortBy          string                 `json:"sortBy,omitempty"`
+	Costs           map[string]float64     `json:"costs,omitempty"`
+	Diverse         bool                   `json:"diverse,omitempty"`
+	CandidatePool   int                    `json:"candidatePool,omitempty"`
+	Constraints     searchalgo.Constraints `json:"constraints,omitempty"`
+	Waypoints       []string               `json:"waypoints,omitempty"`
+	Heuristic       string                 `json:"heuristic,omitempty"`
+	Parallelism     int                    `json:"parallelism,omitempty"`
+	Relaxed         bool                   `json:"relaxed,omitempty"`
+	Budget          searchalgo.Budget      `json:"budget,omitempty"`
+	// most trace events turned into live update steps, zero for the default
+	TraceLimit int `json:"traceLimit,omitempty"`
+	// returns a cursor for the next page, dfs and bfs only
+	Paginate bool `json:"paginate,omitempty"`
+	// continues the enumeration of an earlier paged search
+	Cursor string `json:"cursor,omitempty"`
 }
 
 type ResultStep struct {
@@ -38,10 +60,15 @@ type ResultStep struct {
 }
 
 type RecipeResult struct {
-	Path            []string     `json:"path,omitempty"`
-	Steps           []ResultStep `json:"steps"`
-	TargetElement   string       `json:"targetElement"`
-	StartingElement string       `json:"startingElement"`
+	Path            []string              `json:"path,omitempty"`
+	Steps           []ResultStep          `json:"steps"`
+	TargetElement   string                `json:"targetElement"`
+	StartingElement string                `json:"startingElement"`
+	Scores          searchalgo.TreeScores `json:"scores"`
+	Valid           bool                  `json:"valid"`
+	Issues          []verify.Issue        `json:"issues,omitempty"`
+	// recipes that break the tier rule, only returned by relaxed searches
+	RelaxedRecipes []ResultStep `json:"relaxedRecipes,omitempty"`
 }
 
 type SearchResult struct {
@@ -50,8 +77,99 @@ type SearchResult struct {
 	Metrics struct {
 		Time         float64 `json:"time"`
 		NodesVisited int     `json:"nodesVisited"`
+		Candidates   int     `json:"candidates,omitempty"`
+		Pruned       int     `json:"pruned,omitempty"`
+		Invalid      int     `json:"invalid,omitempty"`
+		Cycles       int     `json:"cycles,omitempty"`
+
+		BudgetHit *searchalgo.BudgetHit `json:"budgetHit,omitempty"`
+		Seed      int64                 `json:"seed,omitempty"`
+		// best tree and optimality proof of the anytime search
+		Anytime *searchalgo.AnytimeMetrics `json:"anytime,omitempty"`
+		Source  string                     `json:"source,omitempty"`
+		// whether the result came from the server cache
+		Cache *searchalgo.CacheMetrics `json:"cache,omitempty"`
+		// trace events left out of the live update steps past the trace limit
+		TraceDropped int `json:"traceDropped,omitempty"`
+		// instrumentation shared by every algorithm, see searchalgo.Stats for
+		// what each field counts
+		Stats searchalgo.Stats `json:"stats"`
+
+		Forward  *searchalgo.DirectionMetrics `json:"forward,omitempty"`
+		Backward *searchalgo.DirectionMetrics `json:"backward,omitempty"`
 	} `json:"metrics"`
 	LiveUpdateSteps []LiveUpdateStep `json:"liveUpdateSteps,omitempty"`
+	// passed back as cursor for the next page, empty on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+type ElementDetails struct {
+	Name         string   `json:"name"`
+	Tier         int      `json:"tier"`
+	IsBase       bool     `json:"isBase"`
+	IconFilename string   `json:"icon_filename"`
+	Recipes      []Recipe `json:"recipes"`
+	TreeCount    string   `json:"treeCount"`
+}
+
+type TreeCountResult struct {
+	Element string `json:"element"`
+	Count   string `json:"count"`
+	Digits  int    `json:"digits"`
+}
+
+type AlgorithmInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// the search request options the algorithm supports, with their types,
+	// defaults and accepted values
+	Options []searchalgo.OptionDef `json:"options"`
+}
+
+type CraftableRequest struct {
+	Inventory []string `json:"inventory"`
+	Depth     int      `json:"depth"`
+}
+
+type PlanRequest struct {
+	Targets   []string `json:"targets"`
+	Inventory []string `json:"inventory"`
+}
+
+type HintRequest struct {
+	Inventory []string `json:"inventory"`
+	Target    string   `json:"target"`
+	Level     string   `json:"level"`
+	Algorithm string   `json:"algorithm,omitempty"`
+}
+
+type ElementUse struct {
+	Element1     string `json:"element1"`
+	Element2     string `json:"element2"`
+	Result       string `json:"result"`
+	ResultTier   int    `json:"resultTier"`
+	IconFilename string `json:"icon_filename"`
+}
+
+type ElementUsesResult struct {
+	Element      string       `json:"element"`
+	Tier         int          `json:"tier"`
+	IconFilename string       `json:"icon_filename"`
+	Uses         []ElementUse `json:"uses"`
+}
+
+type CombinationResult struct {
+	Result       string `json:"result"`
+	Tier         int    `json:"tier"`
+	IconFilename string `json:"icon_filename"`
+}
+
+type CombineResult struct {
+	Element1 string              `json:"element1"`
+	Element2 string              `json:"element2"`
+	Found    bool                `json:"found"`
+	Results  []CombinationResult `json:"results"`
+	Message  string              `json:"message"`
 }
 
 type LiveUpdateStep struct {
@@ -79,17 +197,15 @@ func LoadRecipesFromJSON(filePath string) ([]Recipe, error) {
 	return recipes, nil
 }
 
-func FindRecipe(recipes []Recipe, element1, element2, result string) *Recipe {
-	for _, recipe := range recipes {
-		if (recipe.Element1 == element1 && recipe.Element2 == element2 && recipe.Result == result) ||
-			(recipe.Element1 == element2 && recipe.Element2 == element1 && recipe.Result == result) {
-			return &recipe
-		}
+func FindRecipe(element1, element2, result string) *Recipe {
+	if recipe, exists := utilities.FindRecipe(element1, element2, result); exists {
+		found := Recipe(recipe)
+		return &found
 	}
 	return nil
 }
 
-func BuildRecipeFromString(recipeStrings []string, allRecipes []Recipe) []ResultStep {
+func BuildRecipeFromString(recipeStrings []string) []ResultStep {
 	var steps []ResultStep
 
 	for _, recipeStr := range recipeStrings {
@@ -111,14 +227,8 @@ func BuildRecipeFromString(recipeStrings []string, allRecipes []Recipe) []Result
 		result = parts[1]
 
 		iconFilename := strings.ToLower(result) + ".png" // Default
-		for _, recipe := range allRecipes {
-			if (recipe.Element1 == elem1 && recipe.Element2 == elem2) ||
-				(recipe.Element1 == elem2 && recipe.Element2 == elem1) {
-				if recipe.Result == result {
-					iconFilename = recipe.IconFilename
-					break
-				}
-			}
+		if recipe := FindRecipe(elem1, elem2, result); recipe != nil {
+			iconFilename = recipe.IconFilename
 		}
 
 		steps = append(steps, ResultStep{
@@ -161,13 +271,18 @@ func extractPathElements(tree utilities.RecipeTree) []string {
 	return path
 }
 
-func convertTreesToRecipeResults(trees []utilities.RecipeTree, targetElement string, allRecipes []Recipe) []RecipeResult {
+func convertTreesToRecipeResults(trees []utilities.RecipeTree, scores []searchalgo.TreeScores, targetElement string, startElements []string, relaxed bool) []RecipeResult {
 	var results []RecipeResult
 
-	for _, tree := range trees {
+	for i, tree := range trees {
+		report := verify.Tree(tree, verify.Options{StartElements: startElements, Relaxed: relaxed})
+		for _, issue := range report.Issues {
+			log.Printf("Invalid recipe tree for %s: %s\n", targetElement, issue)
+		}
+
 		recipeStrings := extractRecipeStrings(tree)
 
-		steps := BuildRecipeFromString(recipeStrings, allRecipes)
+		steps := BuildRecipeFromString(recipeStrings)
 
 		var path []string
 		path = extractPathElements(tree)
@@ -182,7 +297,21 @@ func convertTreesToRecipeResults(trees []utilities.RecipeTree, targetElement str
 			Steps:           steps,
 			TargetElement:   targetElement,
 			StartingElement: startingElement,
+			Scores:          scores[i],
+			Valid:           report.Valid,
+			Issues:          report.Issues,
 		})
+		if relaxed {
+			last := &results[len(results)-1]
+			for _, recipe := range searchalgo.RelaxedRecipes(tree) {
+				last.RelaxedRecipes = append(last.RelaxedRecipes, ResultStep{
+					Element1:     recipe.Element1,
+					Element2:     recipe.Element2,
+					Result:       recipe.Result,
+					IconFilename: utilities.FindIconForRecipe(recipe.Element1, recipe.Element2, recipe.Result),
+				})
+			}
+		}
 	}
 
 	return results
@@ -217,123 +346,349 @@ func SearchHandler(w http.ResponseWriter, r *http.Request) {
 		searchReq.StartElements = []string{"Air", "Earth", "Fire", "Water"}
 	}
 
+	searcher, ok := searchalgo.Lookup(searchReq.Algorithm)
+	if !ok {
+		http.Error(w, "Unsupported algorithm", http.StatusBadRequest)
+		return
+	}
+
 	startTime := time.Now()
 	var result SearchResult
 	result.Success = true
 
+	deterministic := searchalgo.DefaultDeterministic
+	if searchReq.Deterministic != nil {
+		deterministic = *searchReq.Deterministic
+	}
+
+	tracer := searchalgo.NewTracer(searchReq.TraceLimit)
+	req := searchalgo.SearchRequest{
+		Target:        searchReq.TargetElement,
+		MaxRecipes:    searchReq.RecipeCount,
+		StartElements: searchReq.StartElements,
+		Deterministic: deterministic,
+		Seed:          searchReq.Seed,
+		SortBy:        searchReq.SortBy,
+		Costs:         searchReq.Costs,
+		Diverse:       searchReq.Diverse,
+		CandidatePool: searchReq.CandidatePool,
+		Constraints:   searchReq.Constraints,
+		Waypoints:     searchReq.Waypoints,
+		Heuristic:     searchReq.Heuristic,
+		Parallelism:   searchReq.Parallelism,
+		Relaxed:       searchReq.Relaxed,
+		Budget:        searchReq.Budget,
+		Trace:         tracer,
+	}
+	var found searchalgo.SearchResult
+	if searchReq.Cursor != "" {
+		found, result.NextCursor, err = searchalgo.ContinueCursor(searcher, req, searchReq.Cursor)
+	} else if searchReq.Paginate {
+		found, result.NextCursor, err = searchalgo.RunPaged(searcher, req)
+	} else {
+		found, err = searchalgo.RunCached(searcher, req)
+	}
+	if errors.Is(err, searchalgo.ErrCursorUnknown) || errors.Is(err, searchalgo.ErrCursorStale) {
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Invalid search request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	trees := found.Trees
+	visited := found.Metrics.NodesVisited
+	result.Metrics.Pruned = found.Metrics.Pruned
+	result.Metrics.Cycles = found.Metrics.Cycles
+	result.Metrics.BudgetHit = found.Metrics.BudgetHit
+	result.Metrics.Seed = found.Metrics.Seed
+	result.Metrics.Source = found.Metrics.Source
+	result.Metrics.Anytime = found.Metrics.Anytime
+	result.Metrics.Cache = found.Metrics.Cache
+	result.Metrics.Stats = found.Metrics.Stats
+	result.Metrics.Forward = found.Metrics.Forward
+	result.Metrics.Backward = found.Metrics.Backward
+
+	result.LiveUpdateSteps = buildLiveUpdateSteps(tracer.Events(), searchReq.TargetElement, searcher.Name())
+	result.Metrics.TraceDropped = tracer.Dropped()
+
+	if len(trees) == 0 {
+		result.Success = false
+		result.Recipes = []RecipeResult{}
+		result.Metrics.Time = float64(time.Since(startTime).Milliseconds())
+		result.Metrics.NodesVisited = visited
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
+	result.Recipes = convertTreesToRecipeResults(trees, found.Scores, searchReq.TargetElement, searchReq.StartElements, searchReq.Relaxed)
+	for _, recipe := range result.Recipes {
+		if !recipe.Valid {
+			result.Metrics.Invalid++
+		}
+	}
+	result.Metrics.Time = float64(time.Since(startTime).Milliseconds())
+	result.Metrics.NodesVisited = visited
+	result.Metrics.Candidates = found.Metrics.Candidates
+
+	log.Printf("tree: %+v\n", trees)
+	log.Printf("Live update steps: %+v\n", result.LiveUpdateSteps)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func ElementsHandler(w http.ResponseWriter, r *http.Request) {
+	// Enable CORS
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
 	workDir, _ := os.Getwd()
 	recipesPath := filepath.Join(workDir, "data", "recipes.json")
-	allRecipes, err := LoadRecipesFromJSON(recipesPath)
+	recipes, err := LoadRecipesFromJSON(recipesPath)
 	if err != nil {
 		http.Error(w, "Failed to load recipes", http.StatusInternalServerError)
 		log.Printf("Error loading recipes: %v", err)
 		return
 	}
 
-	if searchReq.Algorithm == "bfs" {
-		trees, visited, _ := searchalgo.BFSSearch(searchReq.TargetElement, searchReq.RecipeCount)
-		baseElements := searchReq.StartElements
-		var allSteps []LiveUpdateStep
-		for _, tree := range trees {
-			steps := buildLiveUpdateStepsFromTreeWithAlgorithm(tree, allRecipes, baseElements, searchReq.Algorithm)
-			allSteps = append(allSteps, steps...)
-		}
-		result.LiveUpdateSteps = allSteps
-		utilities.SetLiveUpdateCallback(nil)
+	elementMap := make(map[string]bool)
+	for _, recipe := range recipes {
+		elementMap[recipe.Element1] = true
+		elementMap[recipe.Element2] = true
+		elementMap[recipe.Result] = true
+	}
 
-		if len(trees) == 0 {
-			result.Success = false
-			result.Recipes = []RecipeResult{}
-			result.Metrics.Time = float64(time.Since(startTime).Milliseconds())
-			result.Metrics.NodesVisited = visited
+	var elements []string
+	for element := range elementMap {
+		elements = append(elements, element)
+	}
 
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(result)
-			return
-		}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(elements)
+}
 
-		fmt.Printf("[BFS] Visited: %d nodes\n", visited)
+func BasicElementsHandler(w http.ResponseWriter, r *http.Request) {
+	// Enable CORS
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
-		result.Recipes = convertTreesToRecipeResults(trees, searchReq.TargetElement, allRecipes)
-		result.Metrics.Time = float64(time.Since(startTime).Milliseconds())
-		result.Metrics.NodesVisited = visited
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	basicElements := []string{"Air", "Earth", "Fire", "Water"}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(basicElements)
+}
 
-		log.Printf("tree: %+v\n", trees)
-		log.Printf("Live update steps: %+v\n", result.LiveUpdateSteps)
+func AlgorithmsHandler(w http.ResponseWriter, r *http.Request) {
+	// Enable CORS
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
-	} else if searchReq.Algorithm == "dfs" {
-		trees, visited := searchalgo.DFSSearch(searchReq.TargetElement, searchReq.RecipeCount)
-		baseElements := searchReq.StartElements
-		var allSteps []LiveUpdateStep
-		for _, tree := range trees {
-			steps := buildLiveUpdateStepsFromTreeWithAlgorithm(tree, allRecipes, baseElements, searchReq.Algorithm)
-			allSteps = append(allSteps, steps...)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	algorithms := []AlgorithmInfo{}
+	for _, s := range searchalgo.Searchers() {
+		algorithms = append(algorithms, AlgorithmInfo{
+			Name:        s.Name(),
+			Description: s.Description(),
+			Options:     searchalgo.OptionsOf(s),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(algorithms)
+}
+
+// every search request option with the algorithms that support it, from the
+// same definitions as /api/algorithms
+func OptionsHandler(w http.ResponseWriter, r *http.Request) {
+	// Enable CORS
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(searchalgo.OptionDefinitions())
+}
+
+func CompareHandler(w http.ResponseWriter, r *http.Request) {
+	// Enable CORS
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("targets") == "" {
+		http.Error(w, "Missing targets parameter", http.StatusBadRequest)
+		return
+	}
+	targets := strings.Split(query.Get("targets"), ",")
+	for _, target := range targets {
+		if !utilities.ElementExists(target) {
+			http.Error(w, "Element not found: "+target, http.StatusBadRequest)
+			return
 		}
-		result.LiveUpdateSteps = allSteps
-		utilities.SetLiveUpdateCallback(nil)
+	}
 
-		if len(trees) == 0 {
-			result.Success = false
-			result.Recipes = []RecipeResult{}
-			result.Metrics.Time = float64(time.Since(startTime).Milliseconds())
-			result.Metrics.NodesVisited = visited
+	algorithms := []string{"astar", "bfs"}
+	if query.Get("algorithms") != "" {
+		algorithms = strings.Split(query.Get("algorithms"), ",")
+	}
 
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(result)
+	recipeCount := 1
+	if query.Get("recipeCount") != "" {
+		count, err := strconv.Atoi(query.Get("recipeCount"))
+		if err != nil {
+			http.Error(w, "Invalid recipeCount: "+err.Error(), http.StatusBadRequest)
 			return
 		}
+		recipeCount = count
+	}
 
-		fmt.Printf("[DFS] Visited: %d nodes\n", visited)
+	rows, err := searchalgo.Compare(targets, algorithms, recipeCount)
+	if err != nil {
+		http.Error(w, "Comparison failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 
-		result.Recipes = convertTreesToRecipeResults(trees, searchReq.TargetElement, allRecipes)
-		result.Metrics.Time = float64(time.Since(startTime).Milliseconds())
-		result.Metrics.NodesVisited = visited
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rows)
+}
 
-		log.Printf("tree: %+v\n", trees)
-		log.Printf("Live update steps: %+v\n", result.LiveUpdateSteps)
+func ElementDetailsHandler(w http.ResponseWriter, r *http.Request) {
+	// Enable CORS
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
-	} else if searchReq.Algorithm == "bidirectional" {
-		trees, visited := searchalgo.BiDirectionalSearch(searchReq.TargetElement, searchReq.RecipeCount)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 
-		utilities.SetLiveUpdateCallback(nil)
+	if r.Method != "GET" {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-		if len(trees) == 0 {
-			result.Success = false
-			result.Recipes = []RecipeResult{}
-			result.Metrics.Time = float64(time.Since(startTime).Milliseconds())
-			result.Metrics.NodesVisited = visited
+	name := r.PathValue("name")
+	if !utilities.ElementExists(name) {
+		http.Error(w, "Element not found: "+name, http.StatusNotFound)
+		return
+	}
 
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(result)
-			return
-		}
+	details := ElementDetails{
+		Name:         name,
+		Tier:         utilities.Tiers[name],
+		IsBase:       utilities.IsBaseElement(name),
+		IconFilename: utilities.FindIconForElement(name),
+		Recipes:      []Recipe{},
+		TreeCount:    searchalgo.CountRecipeTrees(name).String(),
+	}
+	for _, recipe := range utilities.Recipes[name] {
+		details.Recipes = append(details.Recipes, Recipe(recipe))
+	}
 
-		fmt.Printf("[Bidirectional] Visited: %d nodes\n", visited)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(details)
+}
 
-		result.Recipes = convertTreesToRecipeResults(trees, searchReq.TargetElement, allRecipes)
-		result.Metrics.Time = float64(time.Since(startTime).Milliseconds())
-		result.Metrics.NodesVisited = visited
+func ElementUsesHandler(w http.ResponseWriter, r *http.Request) {
+	// Enable CORS
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
-		baseElements := searchReq.StartElements
-		var allSteps []LiveUpdateStep
-		for _, tree := range trees {
-			steps := buildLiveUpdateStepsFromTreeWithAlgorithm(tree, allRecipes, baseElements, searchReq.Algorithm)
-			allSteps = append(allSteps, steps...)
-		}
-		result.LiveUpdateSteps = allSteps
-		log.Printf("tree: %+v\n", trees)
-		log.Printf("Live update steps: %+v\n", result.LiveUpdateSteps)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 
-	} else {
-		http.Error(w, "Unsupported algorithm", http.StatusBadRequest)
+	if r.Method != "GET" {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.PathValue("name")
+	if !utilities.ElementExists(name) {
+		http.Error(w, "Element not found: "+name, http.StatusNotFound)
 		return
 	}
 
+	result := ElementUsesResult{
+		Element:      name,
+		Tier:         utilities.Tiers[name],
+		IconFilename: utilities.FindIconForElement(name),
+		Uses:         []ElementUse{},
+	}
+	for _, recipe := range utilities.FindUses(name) {
+		result.Uses = append(result.Uses, ElementUse{
+			Element1:     recipe.Element1,
+			Element2:     recipe.Element2,
+			Result:       recipe.Result,
+			ResultTier:   utilities.Tiers[recipe.Result],
+			IconFilename: recipe.IconFilename,
+		})
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
 }
 
-func ElementsHandler(w http.ResponseWriter, r *http.Request) {
+func CombineHandler(w http.ResponseWriter, r *http.Request) {
 	// Enable CORS
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
@@ -349,32 +704,48 @@ func ElementsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	workDir, _ := os.Getwd()
-	recipesPath := filepath.Join(workDir, "data", "recipes.json")
-	recipes, err := LoadRecipesFromJSON(recipesPath)
-	if err != nil {
-		http.Error(w, "Failed to load recipes", http.StatusInternalServerError)
-		log.Printf("Error loading recipes: %v", err)
+	a := r.URL.Query().Get("a")
+	b := r.URL.Query().Get("b")
+	if a == "" || b == "" {
+		http.Error(w, "Missing a or b parameter", http.StatusBadRequest)
 		return
 	}
+	for _, elem := range []string{a, b} {
+		if !utilities.ElementExists(elem) {
+			http.Error(w, "Element not found: "+elem, http.StatusNotFound)
+			return
+		}
+	}
 
-	elementMap := make(map[string]bool)
-	for _, recipe := range recipes {
-		elementMap[recipe.Element1] = true
-		elementMap[recipe.Element2] = true
-		elementMap[recipe.Result] = true
+	result := CombineResult{
+		Element1: a,
+		Element2: b,
+		Results:  []CombinationResult{},
+	}
+	for _, recipe := range utilities.FindCombination(a, b) {
+		result.Results = append(result.Results, CombinationResult{
+			Result:       recipe.Result,
+			Tier:         utilities.Tiers[recipe.Result],
+			IconFilename: recipe.IconFilename,
+		})
 	}
 
-	var elements []string
-	for element := range elementMap {
-		elements = append(elements, element)
+	result.Found = len(result.Results) > 0
+	if result.Found {
+		names := make([]string, len(result.Results))
+		for i, res := range result.Results {
+			names[i] = res.Result
+		}
+		result.Message = fmt.Sprintf("%s + %s makes %s", a, b, strings.Join(names, ", "))
+	} else {
+		result.Message = fmt.Sprintf("%s + %s makes nothing", a, b)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(elements)
+	json.NewEncoder(w).Encode(result)
 }
 
-func BasicElementsHandler(w http.ResponseWriter, r *http.Request) {
+func TreeCountHandler(w http.ResponseWriter, r *http.Request) {
 	// Enable CORS
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
@@ -390,121 +761,334 @@ func BasicElementsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	basicElements := []string{"Air", "Earth", "Fire", "Water"}
+	element := r.URL.Query().Get("element")
+	if element == "" {
+		http.Error(w, "Missing element parameter", http.StatusBadRequest)
+		return
+	}
+	if !utilities.ElementExists(element) {
+		http.Error(w, "Element not found: "+element, http.StatusNotFound)
+		return
+	}
+
+	count := searchalgo.CountRecipeTrees(element).String()
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(basicElements)
+	json.NewEncoder(w).Encode(TreeCountResult{
+		Element: element,
+		Count:   count,
+		Digits:  len(count),
+	})
 }
 
-func buildLiveUpdateStepsForAlgorithm(algorithm string, targetElement string, baseElements []string) []LiveUpdateStep {
-	var steps []LiveUpdateStep
-	stepCounter := 1
+func CraftableHandler(w http.ResponseWriter, r *http.Request) {
+	// Enable CORS
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
-	steps = append(steps, LiveUpdateStep{
-		Step:           stepCounter,
-		Message:        fmt.Sprintf("Starting search for %s using %s algorithm...", targetElement, strings.ToUpper(algorithm)),
-		HighlightNodes: []string{},
-	})
-	stepCounter++
-
-	// Step eksplorasi elemen dasar untuk semua algoritma
-	steps = append(steps, LiveUpdateStep{
-		Step:           stepCounter,
-		Message:        "Exploring basic element combinations...",
-		PartialTree:    &RecipeResult{TargetElement: targetElement},
-		HighlightNodes: baseElements,
-	})
-	stepCounter++
-
-	// Setup callback untuk merekam langkah-langkah specific ke algoritme
-	utilities.SetLiveUpdateCallback(func(element string, path []string, found map[string][]string) {
-		// Buat pesan yang sesuai dengan algoritma
-		var message string
-		switch algorithm {
-		case "bfs":
-			message = fmt.Sprintf("BFS: Exploring element %s", element)
-		case "dfs":
-			message = fmt.Sprintf("DFS: Exploring element %s", element)
-		case "bidirectional":
-			// Untuk bidirectional, tentukan arah pencarian
-			direction := "forward"
-			// Jika element ada di dalam found dan kunci "backward" ada, kemungkinan ini dari arah backward
-			if found != nil {
-				if _, exists := found["backward"]; exists {
-					for _, elem := range found["backward"] {
-						if elem == element {
-							direction = "backward"
-							break
-						}
-					}
-				}
-			}
-			message = fmt.Sprintf("Bidirectional (%s): Exploring element %s", direction, element)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var craftReq CraftableRequest
+	if err := json.NewDecoder(r.Body).Decode(&craftReq); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(craftReq.Inventory) == 0 {
+		craftReq.Inventory = []string{"Air", "Earth", "Fire", "Water"}
+	}
+	for _, elem := range craftReq.Inventory {
+		if !utilities.ElementExists(elem) {
+			http.Error(w, "Element not found: "+elem, http.StatusBadRequest)
+			return
 		}
+	}
 
-		// Tambahkan langkah ke steps
-		steps = append(steps, LiveUpdateStep{
-			Step:           stepCounter,
-			Message:        message,
-			HighlightNodes: append([]string{element}, path...),
-		})
-		stepCounter++
-	})
+	closure := craft.CraftableClosure(craftReq.Inventory, craftReq.Depth)
 
-	return steps
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(closure)
 }
 
-func buildLiveUpdateStepsFromTreeWithAlgorithm(tree utilities.RecipeTree, allRecipes []Recipe, baseElements []string, algorithm string) []LiveUpdateStep {
-	steps := buildLiveUpdateStepsForAlgorithm(algorithm, tree.Element, baseElements)
+func HintHandler(w http.ResponseWriter, r *http.Request) {
+	// Enable CORS
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
-	// Tambahkan langkah-langkah penemuan resep dari tree
-	stepCounter := len(steps) + 1
-	var recipeSteps []ResultStep
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 
-	var search func(node utilities.RecipeTree)
-	search = func(node utilities.RecipeTree) {
-		if len(node.Ingredients) == 2 {
-			// Ambil nama ikon dari resep yang cocok
-			icon := utilities.FindIconForRecipe(node.Ingredients[0].Element, node.Ingredients[1].Element, node.Element)
+	if r.Method != "POST" {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-			step := ResultStep{
-				Element1:     node.Ingredients[0].Element,
-				Element2:     node.Ingredients[1].Element,
-				Result:       node.Element,
-				IconFilename: icon,
-			}
-			recipeSteps = append(recipeSteps, step)
-
-			// Buat pesan yang sesuai dengan algoritma
-			var message string
-			switch algorithm {
-			case "bfs":
-				message = fmt.Sprintf("BFS found combination: %s + %s = %s", step.Element1, step.Element2, step.Result)
-			case "dfs":
-				message = fmt.Sprintf("DFS found combination: %s + %s = %s", step.Element1, step.Element2, step.Result)
-			case "bidirectional":
-				message = fmt.Sprintf("Bidirectional search found combination: %s + %s = %s", step.Element1, step.Element2, step.Result)
-			default:
-				message = fmt.Sprintf("Found combination: %s + %s = %s", step.Element1, step.Element2, step.Result)
-			}
+	var hintReq HintRequest
+	if err := json.NewDecoder(r.Body).Decode(&hintReq); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 
-			steps = append(steps, LiveUpdateStep{
-				Step:    stepCounter,
-				Message: message,
-				PartialTree: &RecipeResult{
-					TargetElement: tree.Element,
-					Steps:         append([]ResultStep{}, recipeSteps...),
-				},
-				HighlightNodes: []string{step.Element1, step.Element2, step.Result},
+	if !utilities.ElementExists(hintReq.Target) {
+		http.Error(w, "Element not found: "+hintReq.Target, http.StatusBadRequest)
+		return
+	}
+	for _, elem := range hintReq.Inventory {
+		if !utilities.ElementExists(elem) {
+			http.Error(w, "Element not found: "+elem, http.StatusBadRequest)
+			return
+		}
+	}
+
+	hint, err := craft.NextHint(hintReq.Inventory, hintReq.Target, hintReq.Level, hintReq.Algorithm)
+	if err != nil {
+		http.Error(w, "No hint available: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hint)
+}
+
+func PlanHandler(w http.ResponseWriter, r *http.Request) {
+	// Enable CORS
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var planReq PlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&planReq); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(planReq.Targets) == 0 {
+		http.Error(w, "Missing targets", http.StatusBadRequest)
+		return
+	}
+	if len(planReq.Inventory) == 0 {
+		planReq.Inventory = []string{"Air", "Earth", "Fire", "Water"}
+	}
+	for _, elem := range append(append([]string{}, planReq.Targets...), planReq.Inventory...) {
+		if !utilities.ElementExists(elem) {
+			http.Error(w, "Element not found: "+elem, http.StatusBadRequest)
+			return
+		}
+	}
+
+	plan, err := craft.PlanTargets(planReq.Targets, planReq.Inventory)
+	if err != nil {
+		http.Error(w, "No plan available: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan)
+}
+
+type AnytimeRequest struct {
+	TargetElement string                 `json:"targetElement"`
+	StartElements []string               `json:"startElements,omitempty"`
+	Constraints   searchalgo.Constraints `json:"constraints,omitempty"`
+	// the time budget is the deadline, searchalgo.DefaultAnytimeMs when left out
+	Budget searchalgo.Budget `json:"budget,omitempty"`
+}
+
+// an "improved" event of the anytime stream
+type AnytimeUpdate struct {
+	Recipe     RecipeResult `json:"recipe"`
+	LowerBound int          `json:"lowerBound"`
+	ElapsedMs  float64      `json:"elapsedMs"`
+}
+
+// the closing "done" event of the anytime stream
+type AnytimeResult struct {
+	Success bool                       `json:"success"`
+	Recipe  *RecipeResult              `json:"recipe,omitempty"`
+	Anytime *searchalgo.AnytimeMetrics `json:"anytime"`
+	// set when the deadline or another budget ended the search
+	BudgetHit *searchalgo.BudgetHit `json:"budgetHit,omitempty"`
+}
+
+// streams the anytime search as server-sent events. every better recipe is
+// sent as an "improved" event while the search runs, and a "done" event
+// carries the best recipe and whether it is proven optimal
+func AnytimeHandler(w http.ResponseWriter, r *http.Request) {
+	// Enable CORS
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var anytimeReq AnytimeRequest
+	if err := json.NewDecoder(r.Body).Decode(&anytimeReq); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !utilities.ElementExists(anytimeReq.TargetElement) {
+		http.Error(w, "Element not found: "+anytimeReq.TargetElement, http.StatusBadRequest)
+		return
+	}
+	if len(anytimeReq.StartElements) == 0 {
+		anytimeReq.StartElements = []string{"Air", "Earth", "Fire", "Water"}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	started := false
+	send := func(event string, data any) {
+		if !started {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			started = true
+		}
+		payload, _ := json.Marshal(data)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+		flusher.Flush()
+	}
+
+	target := anytimeReq.TargetElement
+	recipeOf := func(tree utilities.RecipeTree, scores searchalgo.TreeScores) RecipeResult {
+		return convertTreesToRecipeResults([]utilities.RecipeTree{tree}, []searchalgo.TreeScores{scores}, target, anytimeReq.StartElements, false)[0]
+	}
+
+	searcher, _ := searchalgo.Lookup("anytime")
+	found, err := searchalgo.Run(searcher, searchalgo.SearchRequest{
+		Target:        target,
+		MaxRecipes:    1,
+		StartElements: anytimeReq.StartElements,
+		Constraints:   anytimeReq.Constraints,
+		Budget:        anytimeReq.Budget,
+		OnImprove: func(improved searchalgo.Improvement) {
+			send("improved", AnytimeUpdate{
+				Recipe:     recipeOf(improved.Tree, searchalgo.ScoreTree(improved.Tree, nil)),
+				LowerBound: improved.LowerBound,
+				ElapsedMs:  improved.ElapsedMs,
 			})
-			stepCounter++
+		},
+	})
+	if err != nil {
+		if started {
+			send("error", map[string]string{"error": err.Error()})
+		} else {
+			http.Error(w, "Invalid search request: "+err.Error(), http.StatusBadRequest)
 		}
+		return
+	}
+
+	done := AnytimeResult{
+		Success:   len(found.Trees) > 0,
+		Anytime:   found.Metrics.Anytime,
+		BudgetHit: found.Metrics.BudgetHit,
+	}
+	if done.Success {
+		recipe := recipeOf(found.Trees[0], found.Scores[0])
+		done.Recipe = &recipe
+	}
+	send("done", done)
+}
 
-		for _, ing := range node.Ingredients {
-			search(ing)
+// turns the trace of a search into the steps the visualizer replays. every found
+// event carries the partial tree made of the latest recipe found per element
+func buildLiveUpdateSteps(events []searchalgo.TraceEvent, targetElement string, algorithm string) []LiveUpdateStep {
+	steps := []LiveUpdateStep{{
+		Step:           1,
+		Message:        fmt.Sprintf("Starting search for %s using %s algorithm...", targetElement, strings.ToUpper(algorithm)),
+		HighlightNodes: []string{},
+	}}
+
+	latest := make(map[string]ResultStep)
+	partialTree := func() *RecipeResult {
+		partial := &RecipeResult{TargetElement: targetElement, Steps: []ResultStep{}}
+		seen := make(map[string]bool)
+		queue := []string{targetElement}
+		for len(queue) > 0 {
+			element := queue[0]
+			queue = queue[1:]
+			step, ok := latest[element]
+			if !ok || seen[element] {
+				continue
+			}
+			seen[element] = true
+			partial.Steps = append(partial.Steps, step)
+			queue = append(queue, step.Element1, step.Element2)
+		}
+		return partial
+	}
+
+	for _, event := range events {
+		label := strings.ToUpper(algorithm)
+		if event.Direction != "" {
+			label += " (" + event.Direction + ")"
+		}
+
+		step := LiveUpdateStep{
+			Step:           len(steps) + 1,
+			HighlightNodes: []string{event.Element},
+		}
+		switch event.Kind {
+		case searchalgo.TraceVisit:
+			step.Message = fmt.Sprintf("%s: Exploring element %s", label, event.Element)
+		case searchalgo.TraceEnqueue:
+			step.Message = fmt.Sprintf("%s: Queued %s at depth %d", label, event.Element, event.Depth)
+		case searchalgo.TracePrune:
+			if event.Recipe != nil {
+				step.Message = fmt.Sprintf("%s: Pruned %s + %s = %s (%s)", label, event.Recipe.Element1, event.Recipe.Element2, event.Element, event.Reason)
+				step.HighlightNodes = []string{event.Recipe.Element1, event.Recipe.Element2, event.Element}
+			} else {
+				step.Message = fmt.Sprintf("%s: Pruned a tree for %s (%s)", label, event.Element, event.Reason)
+			}
+		case searchalgo.TraceFound:
+			recipe := event.Recipe
+			latest[recipe.Result] = ResultStep{
+				Element1:     recipe.Element1,
+				Element2:     recipe.Element2,
+				Result:       recipe.Result,
+				IconFilename: utilities.FindIconForRecipe(recipe.Element1, recipe.Element2, recipe.Result),
+			}
+			step.Message = fmt.Sprintf("%s found combination: %s + %s = %s", label, recipe.Element1, recipe.Element2, recipe.Result)
+			step.PartialTree = partialTree()
+			step.HighlightNodes = []string{recipe.Element1, recipe.Element2, recipe.Result}
+		default:
+			continue
 		}
+		steps = append(steps, step)
 	}
 
-	search(tree)
 	return steps
 }
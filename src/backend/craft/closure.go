@@ -0,0 +1,82 @@
+package craft
+
+import (
+	"tubes2/utilities"
+)
+
+// an element that can be crafted straight from the inventory
+type Craftable struct {
+	Element string             `json:"element"`
+	Tier    int                `json:"tier"`
+	Recipes []utilities.Recipe `json:"recipes"`
+}
+
+// the elements that first become reachable after Depth more crafts
+type ReachLayer struct {
+	Depth    int      `json:"depth"`
+	Elements []string `json:"elements"`
+}
+
+type Closure struct {
+	Inventory []string     `json:"inventory"`
+	Craftable []Craftable  `json:"craftable"`
+	Layers    []ReachLayer `json:"layers"`
+	Reachable int          `json:"reachable"`
+}
+
+// expands the inventory forward over the recipe graph. layer 1 holds what can be
+// crafted right now, layer n what needs n-1 other new elements first. a depth
+// of 0 keeps going until nothing new can be made. the tier rule does not apply
+// here, any recipe in the book can be used once both ingredients are owned
+func CraftableClosure(inventory []string, maxDepth int) Closure {
+	owned := make(map[string]bool)
+	closure := Closure{Craftable: []Craftable{}, Layers: []ReachLayer{}}
+	for _, elem := range inventory {
+		if !owned[elem] {
+			owned[elem] = true
+			closure.Inventory = append(closure.Inventory, elem)
+		}
+	}
+
+	for depth := 1; maxDepth <= 0 || depth <= maxDepth; depth++ {
+		var layer []string
+		for _, result := range utilities.ResultElements {
+			if owned[result] {
+				continue
+			}
+
+			var producing []utilities.Recipe
+			for _, recipe := range utilities.Recipes[result] {
+				if owned[recipe.Element1] && owned[recipe.Element2] {
+					producing = append(producing, recipe)
+				}
+			}
+			if len(producing) == 0 {
+				continue
+			}
+
+			layer = append(layer, result)
+			if depth == 1 {
+				closure.Craftable = append(closure.Craftable, Craftable{
+					Element: result,
+					Tier:    utilities.Tiers[result],
+					Recipes: producing,
+				})
+			}
+		}
+
+		if len(layer) == 0 {
+			break
+		}
+
+		// only mark the layer as owned once it is complete, otherwise an element
+		// could unlock another one within the same layer
+		for _, elem := range layer {
+			owned[elem] = true
+		}
+		closure.Layers = append(closure.Layers, ReachLayer{Depth: depth, Elements: layer})
+		closure.Reachable += len(layer)
+	}
+
+	return closure
+}
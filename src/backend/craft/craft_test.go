@@ -0,0 +1,150 @@
+package craft
+
+import (
+	"os"
+	"testing"
+	"tubes2/searchalgo"
+	"tubes2/utilities"
+)
+
+const testRecipesPath = "../testdata/recipes.json"
+
+func TestMain(m *testing.M) {
+	utilities.LoadRecipes(testRecipesPath)
+	searchalgo.DefaultDeterministic = true
+	os.Exit(m.Run())
+}
+
+var baseInventory = []string{"Air", "Earth", "Fire", "Water"}
+
+func ownedSet(elements []string) map[string]bool {
+	owned := make(map[string]bool)
+	for _, elem := range elements {
+		owned[elem] = true
+	}
+	return owned
+}
+
+func craftableFrom(owned map[string]bool, element string) bool {
+	for _, recipe := range utilities.Recipes[element] {
+		if owned[recipe.Element1] && owned[recipe.Element2] {
+			return true
+		}
+	}
+	return false
+}
+
+func TestClosureLayersFollowTheRecipes(t *testing.T) {
+	closure := CraftableClosure(baseInventory, 0)
+
+	owned := ownedSet(baseInventory)
+	total := 0
+	for _, layer := range closure.Layers {
+		for _, elem := range layer.Elements {
+			if !craftableFrom(owned, elem) {
+				t.Errorf("%s in layer %d cannot be made from the earlier layers", elem, layer.Depth)
+			}
+		}
+		for _, elem := range layer.Elements {
+			owned[elem] = true
+		}
+		total += len(layer.Elements)
+	}
+	if closure.Reachable != total {
+		t.Errorf("reachable is %d, the layers hold %d elements", closure.Reachable, total)
+	}
+
+	// every element with a tier is made from the base elements, so the full
+	// closure of the base elements reaches exactly those
+	for _, elem := range utilities.ResultElements {
+		_, tiered := utilities.Tiers[elem]
+		if owned[elem] != tiered {
+			t.Errorf("%s: reached %v, has a tier %v", elem, owned[elem], tiered)
+		}
+	}
+
+	if len(closure.Layers) == 0 {
+		t.Fatal("nothing is craftable from the base elements")
+	}
+	if len(closure.Craftable) != len(closure.Layers[0].Elements) {
+		t.Errorf("%d craftable elements, the first layer holds %d", len(closure.Craftable), len(closure.Layers[0].Elements))
+	}
+	for _, c := range closure.Craftable {
+		for _, recipe := range c.Recipes {
+			if !ownedSet(baseInventory)[recipe.Element1] || !ownedSet(baseInventory)[recipe.Element2] {
+				t.Errorf("%s is listed with %s + %s, which are not in the inventory", c.Element, recipe.Element1, recipe.Element2)
+			}
+		}
+	}
+
+	if limited := CraftableClosure(baseInventory, 2); len(limited.Layers) != 2 {
+		t.Errorf("a depth of 2 gave %d layers", len(limited.Layers))
+	}
+}
+
+// following the exact hints one combination at a time has to reach the target
+func TestExactHintsReachTheTarget(t *testing.T) {
+	for _, target := range []string{"Brick", "Airplane", "Astronomer"} {
+		t.Run(target, func(t *testing.T) {
+			inventory := append([]string{}, baseInventory...)
+			first, err := NextHint(inventory, target, HintExact, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			hint := first
+			for crafts := 0; !hint.Done; crafts++ {
+				if crafts > first.StepsRemaining {
+					t.Fatalf("not done after the %d steps the first hint announced", first.StepsRemaining+1)
+				}
+				c := hint.Combination
+				owned := ownedSet(inventory)
+				if !owned[c.Element1] || !owned[c.Element2] {
+					t.Fatalf("hint %s + %s uses an element that is not owned", c.Element1, c.Element2)
+				}
+				if _, ok := utilities.FindRecipe(c.Element1, c.Element2, c.Result); !ok {
+					t.Fatalf("hint %s + %s => %s is not a recipe", c.Element1, c.Element2, c.Result)
+				}
+				inventory = append(inventory, c.Result)
+
+				if hint, err = NextHint(inventory, target, HintExact, ""); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanStepsOnlyUseWhatIsMade(t *testing.T) {
+	targets := []string{"Airplane", "Ambulance", "Astronomer"}
+	plan, err := PlanTargets(targets, baseInventory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owned := ownedSet(baseInventory)
+	for _, step := range plan.Steps {
+		if !owned[step.Element1] || !owned[step.Element2] {
+			t.Errorf("step %d (%s + %s) needs an element no earlier step made", step.Step, step.Element1, step.Element2)
+		}
+		if _, ok := utilities.FindRecipe(step.Element1, step.Element2, step.Result); !ok {
+			t.Errorf("step %d (%s + %s => %s) is not a recipe", step.Step, step.Element1, step.Element2, step.Result)
+		}
+		if owned[step.Result] {
+			t.Errorf("step %d makes %s a second time", step.Step, step.Result)
+		}
+		owned[step.Result] = true
+	}
+	for _, target := range targets {
+		if !owned[target] {
+			t.Errorf("no step makes %s", target)
+		}
+	}
+
+	if plan.TotalSteps != len(plan.Steps) {
+		t.Errorf("total steps is %d for %d steps", plan.TotalSteps, len(plan.Steps))
+	}
+	if plan.TotalSteps > plan.SeparateSteps {
+		t.Errorf("the merged plan takes %d steps, planning each target alone takes %d", plan.TotalSteps, plan.SeparateSteps)
+	}
+}
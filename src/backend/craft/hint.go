@@ -0,0 +1,157 @@
+package craft
+
+import (
+	"fmt"
+	"tubes2/searchalgo"
+	"tubes2/utilities"
+)
+
+// hint levels, each one reveals a little more than the previous
+const (
+	HintVague      = "vague"
+	HintIngredient = "ingredient"
+	HintExact      = "exact"
+)
+
+type Hint struct {
+	Target         string            `json:"target"`
+	Level          string            `json:"level"`
+	Message        string            `json:"message"`
+	SubGoal        string            `json:"subGoal,omitempty"`
+	Ingredient     string            `json:"ingredient,omitempty"`
+	Combination    *utilities.Recipe `json:"combination,omitempty"`
+	StepsRemaining int               `json:"stepsRemaining"`
+	Done           bool              `json:"done"`
+}
+
+// a combination that can be made right now together with where it sits in the plan
+type readyStep struct {
+	node   utilities.RecipeTree
+	parent string
+	depth  int
+}
+
+// picks the single best next combination towards the target. the plan comes
+// from the cheapest tree over the inventory, or from the given search algorithm
+// when one is named. the best step is the ready combination deepest in that
+// plan, since it sits on the longest chain still left to craft
+func NextHint(inventory []string, target string, level string, algorithm string) (Hint, error) {
+	if level == "" {
+		level = HintVague
+	}
+	if level != HintVague && level != HintIngredient && level != HintExact {
+		return Hint{}, fmt.Errorf("unknown hint level %q", level)
+	}
+
+	owned := make(map[string]bool)
+	for _, elem := range inventory {
+		owned[elem] = true
+	}
+
+	hint := Hint{Target: target, Level: level}
+	if owned[target] || utilities.IsBaseElement(target) {
+		hint.Done = true
+		hint.Message = fmt.Sprintf("You already have %s.", target)
+		return hint, nil
+	}
+
+	plan, err := planTree(target, owned, algorithm)
+	if err != nil {
+		return Hint{}, err
+	}
+
+	var best *readyStep
+	var walk func(node utilities.RecipeTree, parent string, depth int)
+	walk = func(node utilities.RecipeTree, parent string, depth int) {
+		if len(node.Ingredients) == 0 {
+			return
+		}
+		if isLeaf(node.Ingredients[0]) && isLeaf(node.Ingredients[1]) {
+			if best == nil || depth > best.depth {
+				best = &readyStep{node: node, parent: parent, depth: depth}
+			}
+			return
+		}
+		for _, ing := range node.Ingredients {
+			walk(ing, node.Element, depth+1)
+		}
+	}
+	walk(plan, target, 0)
+
+	e1, e2 := best.node.Ingredients[0].Element, best.node.Ingredients[1].Element
+	result := best.node.Element
+
+	hint.SubGoal = best.parent
+	hint.StepsRemaining = distinctCrafts(plan) - 1
+
+	switch level {
+	case HintVague:
+		if result == target {
+			hint.Message = fmt.Sprintf("You are one combination away from %s.", target)
+		} else {
+			hint.Message = fmt.Sprintf("Work towards %s.", best.parent)
+		}
+	case HintIngredient:
+		hint.Ingredient = e1
+		hint.Message = fmt.Sprintf("Try combining %s with something to get closer to %s.", e1, best.parent)
+	case HintExact:
+		hint.Ingredient = e1
+		hint.Combination = &utilities.Recipe{
+			Element1:     e1,
+			Element2:     e2,
+			Result:       result,
+			IconFilename: utilities.FindIconForRecipe(e1, e2, result),
+		}
+		hint.Message = fmt.Sprintf("Combine %s + %s to make %s.", e1, e2, result)
+	}
+
+	return hint, nil
+}
+
+// the tree still left to craft, with everything already owned cut off as a leaf
+func planTree(target string, owned map[string]bool, algorithm string) (utilities.RecipeTree, error) {
+	if algorithm == "" {
+		tree, _, ok := searchalgo.CheapestTree(target, owned)
+		if !ok {
+			return utilities.RecipeTree{}, fmt.Errorf("no recipe found for %s", target)
+		}
+		return tree, nil
+	}
+
+	searcher, ok := searchalgo.Lookup(algorithm)
+	if !ok {
+		return utilities.RecipeTree{}, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+	found, err := searchalgo.Run(searcher, searchalgo.SearchRequest{
+		Target:        target,
+		MaxRecipes:    1,
+		Deterministic: true,
+	})
+	if err != nil {
+		return utilities.RecipeTree{}, err
+	}
+	if len(found.Trees) == 0 {
+		return utilities.RecipeTree{}, fmt.Errorf("no recipe found for %s", target)
+	}
+	return pruneOwned(found.Trees[0], owned, true), nil
+}
+
+func pruneOwned(tree utilities.RecipeTree, owned map[string]bool, root bool) utilities.RecipeTree {
+	if !root && owned[tree.Element] {
+		return utilities.RecipeTree{Element: tree.Element}
+	}
+	pruned := utilities.RecipeTree{Element: tree.Element}
+	for _, ing := range tree.Ingredients {
+		pruned.Ingredients = append(pruned.Ingredients, pruneOwned(ing, owned, false))
+	}
+	return pruned
+}
+
+func isLeaf(tree utilities.RecipeTree) bool {
+	return len(tree.Ingredients) == 0
+}
+
+// every element only has to be crafted once, however often the tree uses it
+func distinctCrafts(tree utilities.RecipeTree) int {
+	return len(craftedIn(tree))
+}
@@ -0,0 +1,179 @@
+package craft
+
+import (
+	"fmt"
+	"sort"
+	"tubes2/searchalgo"
+	"tubes2/utilities"
+)
+
+// one combination of the merged plan. the ingredients of a step are owned or
+// made by an earlier step, and targets lists every target that needs it
+type PlanStep struct {
+	Step         int      `json:"step"`
+	Element1     string   `json:"element1"`
+	Element2     string   `json:"element2"`
+	Result       string   `json:"result"`
+	IconFilename string   `json:"icon_filename"`
+	Targets      []string `json:"targets"`
+}
+
+// the part of the merged plan one target needs
+type TargetPlan struct {
+	Target string `json:"target"`
+	Owned  bool   `json:"owned"`
+	// crafts below this target, each counted once
+	Steps int `json:"steps"`
+	// of those, the crafts another target needs as well
+	Shared int                  `json:"shared"`
+	Tree   utilities.RecipeTree `json:"tree"`
+}
+
+type Plan struct {
+	Inventory []string     `json:"inventory"`
+	Targets   []TargetPlan `json:"targets"`
+	Steps     []PlanStep   `json:"steps"`
+	// crafts of the merged plan, every shared intermediate is made once
+	TotalSteps int `json:"totalSteps"`
+	// crafts needed when every target is planned on its own from the inventory
+	SeparateSteps int `json:"separateSteps"`
+}
+
+// plans several targets at once. the targets are planned from the highest tier
+// down, and every later target treats the intermediates already planned as
+// owned, so its cheapest tree reuses them instead of crafting them again.
+// the recipes picked for all targets form one ingredient map, so each target's
+// tree is built from the same choices and the steps form a DAG
+func PlanTargets(targets []string, inventory []string) (Plan, error) {
+	owned := make(map[string]bool)
+	plan := Plan{Inventory: []string{}, Targets: []TargetPlan{}, Steps: []PlanStep{}}
+	for _, elem := range inventory {
+		if !owned[elem] {
+			owned[elem] = true
+			plan.Inventory = append(plan.Inventory, elem)
+		}
+	}
+
+	var unique []string
+	seen := make(map[string]bool)
+	for _, target := range targets {
+		if !seen[target] {
+			seen[target] = true
+			unique = append(unique, target)
+		}
+	}
+
+	order := append([]string{}, unique...)
+	sort.SliceStable(order, func(i, j int) bool {
+		return utilities.Tiers[order[i]] > utilities.Tiers[order[j]]
+	})
+
+	found := make(map[string][]string)
+	for _, target := range order {
+		if owned[target] || utilities.IsBaseElement(target) || found[target] != nil {
+			continue
+		}
+
+		available := make(map[string]bool)
+		for elem := range owned {
+			available[elem] = true
+		}
+		for elem := range found {
+			available[elem] = true
+		}
+
+		tree, _, ok := searchalgo.CheapestTree(target, available)
+		if !ok {
+			return Plan{}, fmt.Errorf("no recipe found for %s", target)
+		}
+		recordChoices(tree, found)
+	}
+
+	users := make(map[string][]string)
+	for _, target := range unique {
+		tp := TargetPlan{
+			Target: target,
+			Owned:  owned[target] || utilities.IsBaseElement(target),
+			Tree:   utilities.RecipeTree{Element: target},
+		}
+		if !tp.Owned {
+			tp.Tree = utilities.BuildRecipeTree(target, found)
+			separate, _, _ := searchalgo.CheapestTree(target, owned)
+			plan.SeparateSteps += distinctCrafts(separate)
+		}
+		for elem := range craftedIn(tp.Tree) {
+			users[elem] = append(users[elem], target)
+		}
+		plan.Targets = append(plan.Targets, tp)
+	}
+
+	for i := range plan.Targets {
+		tp := &plan.Targets[i]
+		for elem := range craftedIn(tp.Tree) {
+			tp.Steps++
+			if len(users[elem]) > 1 {
+				tp.Shared++
+			}
+		}
+	}
+
+	// ingredients before results, in the order the targets were asked for
+	done := make(map[string]bool)
+	var visit func(element string)
+	visit = func(element string) {
+		ingredients := found[element]
+		if done[element] || ingredients == nil {
+			return
+		}
+		done[element] = true
+		visit(ingredients[0])
+		visit(ingredients[1])
+
+		e1, e2 := ingredients[0], ingredients[1]
+		plan.Steps = append(plan.Steps, PlanStep{
+			Step:         len(plan.Steps) + 1,
+			Element1:     e1,
+			Element2:     e2,
+			Result:       element,
+			IconFilename: utilities.FindIconForRecipe(e1, e2, element),
+			Targets:      users[element],
+		})
+	}
+	for _, tp := range plan.Targets {
+		if !tp.Owned {
+			visit(tp.Target)
+		}
+	}
+	plan.TotalSteps = len(plan.Steps)
+
+	return plan, nil
+}
+
+func recordChoices(tree utilities.RecipeTree, found map[string][]string) {
+	if len(tree.Ingredients) != 2 {
+		return
+	}
+	found[tree.Element] = []string{tree.Ingredients[0].Element, tree.Ingredients[1].Element}
+	for _, ing := range tree.Ingredients {
+		recordChoices(ing, found)
+	}
+}
+
+// elements a tree crafts, each once
+func craftedIn(tree utilities.RecipeTree) map[string]bool {
+	crafted := make(map[string]bool)
+
+	var walk func(node utilities.RecipeTree)
+	walk = func(node utilities.RecipeTree) {
+		if len(node.Ingredients) == 0 {
+			return
+		}
+		crafted[node.Element] = true
+		for _, ing := range node.Ingredients {
+			walk(ing)
+		}
+	}
+	walk(tree)
+
+	return crafted
+}
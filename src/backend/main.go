@@ -2,12 +2,17 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 	"tubes2/api"
 	"tubes2/scraper"
+	"tubes2/searchalgo"
 	"tubes2/utilities"
 )
 
@@ -15,14 +20,48 @@ func main() {
 	// Ensure data directory exists
 	workDir, _ := os.Getwd()
 	recipesPath := filepath.Join(workDir, "data", "recipes.json")
-	scraper.ScrapeIfNeeded(recipesPath)
 
 	// Command line flags
-	utilities.LoadRecipes("data/recipes.json")
 	portPtr := flag.String("port", "8080", "Port for the server to listen on")
-	modePtr := flag.String("mode", "server", "Mode to run (server or test)")
+	modePtr := flag.String("mode", "server", "Mode to run (server, poolbench, bench or precompute)")
+	poolsPtr := flag.String("pools", "1,2,4,8", "Worker pool sizes compared by poolbench")
+	targetsPtr := flag.String("targets", "Brick,Human,Airplane", "Target elements searched by poolbench")
+	recipeCountPtr := flag.Int("recipes", 10, "Recipes searched per target by poolbench")
+	roundsPtr := flag.Int("rounds", 3, "Repetitions per pool size in poolbench")
+	elementsPtr := flag.String("elements", "", "Target elements searched by bench, empty for every element")
+	algorithmsPtr := flag.String("algorithms", "", "Algorithms run by bench, empty for every registered one")
+	countsPtr := flag.String("counts", "1,5", "Recipe counts searched per target by bench")
+	outPtr := flag.String("out", "bench", "Report path of bench without extension, a .csv and a .json are written")
+	maxNodesPtr := flag.Int("max-nodes", 2000000, "Most nodes a single search may visit, 0 for no limit")
+	maxTimePtr := flag.Duration("max-time", 30*time.Second, "Longest a single search may run, 0 for no limit")
+	maxMemoryPtr := flag.Int("max-memory", 0, "Approximate heap ceiling in MB checked during searches, 0 for no limit")
+	cacheSizePtr := flag.Int("cache-size", searchalgo.DefaultCacheSize, "Search results kept in the server cache, 0 disables it")
+	cursorTTLPtr := flag.Duration("cursor-ttl", searchalgo.CursorTTL, "How long an unused paging cursor stays valid")
 	flag.Parse()
 
+	// the benchmark and the precomputation only run against the recipes already on disk
+	if *modePtr == "bench" || *modePtr == "precompute" {
+		if _, err := os.Stat(recipesPath); err != nil {
+			log.Fatalf("Recipes file not found: %s\n%s runs offline and needs an existing recipes.json", recipesPath, *modePtr)
+		}
+	} else {
+		scraper.ScrapeIfNeeded(recipesPath)
+	}
+	utilities.LoadRecipes(recipesPath)
+
+	// the precompute mode always rebuilds the table, the others load or build it
+	// the first time the precomputed algorithm runs
+	minimalPath := searchalgo.MinimalTablePath(recipesPath)
+	searchalgo.MinimalTableLocation = minimalPath
+
+	searchalgo.ServerBudget = searchalgo.Budget{
+		MaxNodes:    *maxNodesPtr,
+		MaxTimeMs:   int(maxTimePtr.Milliseconds()),
+		MaxMemoryMB: *maxMemoryPtr,
+	}
+	searchalgo.Cache = searchalgo.NewResultCache(*cacheSizePtr)
+	searchalgo.CursorTTL = *cursorTTLPtr
+
 	// if _, err := os.Stat(recipesPath); os.IsNotExist(err) {
 	// 	log.Fatalf("Recipes file not found: %s\nMake sure the 'data' directory with 'recipes.json' exists", recipesPath)
 	// }
@@ -31,8 +70,107 @@ func main() {
 	if *modePtr == "server" {
 		// Start the server
 		runServer(*portPtr)
+	} else if *modePtr == "poolbench" {
+		runPoolBench(*poolsPtr, *targetsPtr, *recipeCountPtr, *roundsPtr)
+	} else if *modePtr == "bench" {
+		runBench(*elementsPtr, *algorithmsPtr, *countsPtr, *outPtr)
+	} else if *modePtr == "precompute" {
+		os.Remove(minimalPath)
+		if _, err := searchalgo.PrepareMinimalTable(minimalPath); err != nil {
+			log.Fatalf("Failed to write minimal recipe table: %v", err)
+		}
+		log.Printf("Precomputed minimal recipe table written to %s", minimalPath)
+	} else {
+		log.Fatalf("Invalid mode: %s. Use 'server', 'poolbench', 'bench' or 'precompute'", *modePtr)
+	}
+}
+
+func runBench(elements string, algorithms string, counts string, out string) {
+	var targetList []string
+	if elements == "" {
+		targetList = append(targetList, utilities.ResultElements...)
 	} else {
-		log.Fatalf("Invalid mode: %s. Use 'server'", *modePtr)
+		for _, t := range strings.Split(elements, ",") {
+			t = strings.TrimSpace(t)
+			if _, ok := utilities.Recipes[t]; !ok {
+				log.Fatalf("Unknown target element: %q", t)
+			}
+			targetList = append(targetList, t)
+		}
+	}
+
+	var algorithmList []string
+	if algorithms == "" {
+		for _, s := range searchalgo.Searchers() {
+			algorithmList = append(algorithmList, s.Name())
+		}
+	} else {
+		for _, a := range strings.Split(algorithms, ",") {
+			algorithmList = append(algorithmList, strings.TrimSpace(a))
+		}
+	}
+
+	var recipeCounts []int
+	for _, c := range strings.Split(counts, ",") {
+		count, err := strconv.Atoi(strings.TrimSpace(c))
+		if err != nil || count <= 0 {
+			log.Fatalf("Invalid recipe count: %q", c)
+		}
+		recipeCounts = append(recipeCounts, count)
+	}
+
+	report, err := searchalgo.Benchmark(targetList, algorithmList, recipeCounts)
+	if err != nil {
+		log.Fatalf("Benchmark failed: %v", err)
+	}
+
+	writeReport := func(path string, write func(f *os.File) error) {
+		f, err := os.Create(path)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", path, err)
+		}
+		defer f.Close()
+		if err := write(f); err != nil {
+			log.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	writeReport(out+".csv", func(f *os.File) error {
+		return searchalgo.WriteBenchCSV(f, report.Rows)
+	})
+	writeReport(out+".json", func(f *os.File) error {
+		return searchalgo.WriteBenchJSON(f, report)
+	})
+
+	fmt.Printf("%-5s %-14s %-7s %-5s %-6s %-8s %-10s %-12s %s\n", "tier", "algorithm", "recipes", "runs", "found", "invalid", "avg ms", "avg expanded", "avg allocs")
+	for _, sum := range report.Summary {
+		fmt.Printf("%-5d %-14s %-7d %-5d %-6d %-8d %-10.3f %-12.1f %.0f\n", sum.Tier, sum.Algorithm, sum.RecipeCount, sum.Runs, sum.Found, sum.Invalid, sum.AvgTime, sum.AvgExpansions, sum.AvgAllocs)
+	}
+	fmt.Printf("Wrote %d runs to %s.csv and %s.json\n", len(report.Rows), out, out)
+}
+
+func runPoolBench(pools string, targets string, recipeCount int, rounds int) {
+	var poolSizes []int
+	for _, p := range strings.Split(pools, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || size <= 0 {
+			log.Fatalf("Invalid pool size: %q", p)
+		}
+		poolSizes = append(poolSizes, size)
+	}
+
+	var targetList []string
+	for _, t := range strings.Split(targets, ",") {
+		t = strings.TrimSpace(t)
+		if _, ok := utilities.Recipes[t]; !ok {
+			log.Fatalf("Unknown target element: %q", t)
+		}
+		targetList = append(targetList, t)
+	}
+
+	rows := searchalgo.CompareBFSPools(targetList, poolSizes, recipeCount, rounds)
+	fmt.Printf("%-8s %-9s %-10s %-12s %s\n", "workers", "searches", "visited", "avg ms", "speedup")
+	for _, row := range rows {
+		fmt.Printf("%-8d %-9d %-10d %-12.3f %.2fx\n", row.Workers, row.Searches, row.Visited, row.AvgTime, row.Speedup)
 	}
 }
 
@@ -42,6 +180,17 @@ func runServer(port string) {
 	mux.HandleFunc("/api/search", api.SearchHandler)
 	mux.HandleFunc("/api/elements", api.ElementsHandler)
 	mux.HandleFunc("/api/elements/basic", api.BasicElementsHandler)
+	mux.HandleFunc("/api/elements/{name}", api.ElementDetailsHandler)
+	mux.HandleFunc("/api/elements/{name}/uses", api.ElementUsesHandler)
+	mux.HandleFunc("/api/combine", api.CombineHandler)
+	mux.HandleFunc("/api/count", api.TreeCountHandler)
+	mux.HandleFunc("/api/algorithms", api.AlgorithmsHandler)
+	mux.HandleFunc("/api/options", api.OptionsHandler)
+	mux.HandleFunc("/api/compare", api.CompareHandler)
+	mux.HandleFunc("/api/craftable", api.CraftableHandler)
+	mux.HandleFunc("/api/hint", api.HintHandler)
+	mux.HandleFunc("/api/plan", api.PlanHandler)
+	mux.HandleFunc("/api/anytime", api.AnytimeHandler)
 
 	// Serve static files for the frontend
 	workDir, _ := os.Getwd()
@@ -52,6 +201,6 @@ func runServer(port string) {
 	// Start the server
 	addr := ":" + port
 	log.Printf("Server started on http://localhost%s", addr)
-	log.Printf("API endpoints: /api/search, /api/elements, /api/elements/basic")
+	log.Printf("API endpoints: /api/search, /api/elements, /api/elements/basic, /api/elements/{name}, /api/elements/{name}/uses, /api/combine, /api/count, /api/algorithms, /api/options, /api/compare, /api/craftable, /api/hint, /api/plan, /api/anytime")
 	log.Fatal(http.ListenAndServe(addr, mux))
 }
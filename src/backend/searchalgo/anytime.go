@@ -0,0 +1,191 @@
+package searchalgo
+
+import (
+	"sort"
+	"time"
+	"tubes2/utilities"
+)
+
+// how long the anytime search keeps improving when the request sets no time budget
+const DefaultAnytimeMs = 5000
+
+// a better tree found by the anytime search, handed to SearchRequest.OnImprove
+type Improvement struct {
+	Tree  utilities.RecipeTree `json:"tree"`
+	Steps int                  `json:"steps"`
+	// no tree of the target needs fewer steps than this
+	LowerBound int     `json:"lowerBound"`
+	ElapsedMs  float64 `json:"elapsedMs"`
+}
+
+type AnytimeMetrics struct {
+	Improvements int `json:"improvements"`
+	Steps        int `json:"steps"`
+	LowerBound   int `json:"lowerBound"`
+	// the whole search space was ruled out, or the best tree met the lower bound
+	ProvenOptimal bool    `json:"provenOptimal"`
+	FirstMs       float64 `json:"firstMs"`
+	BestMs        float64 `json:"bestMs"`
+}
+
+type anytimeSearcher struct{}
+
+func init() {
+	Register(anytimeSearcher{})
+}
+
+func (anytimeSearcher) Name() string {
+	return "anytime"
+}
+
+func (anytimeSearcher) Description() string {
+	return "Returns a feasible recipe quickly and keeps improving it toward fewer steps until the time budget runs out"
+}
+
+func (anytimeSearcher) Options() []string {
+	return []string{"budget", "streaming"}
+}
+
+func (anytimeSearcher) defaultBudget() Budget {
+	return Budget{MaxTimeMs: DefaultAnytimeMs}
+}
+
+func (anytimeSearcher) Search(req SearchRequest) SearchResult {
+	ctx := newSearchContext(req)
+	s := &anytimeSearch{ctx: ctx, start: time.Now()}
+	tree, ok := s.run()
+
+	var trees []utilities.RecipeTree
+	if ok {
+		trees = append(trees, tree)
+	}
+	result := ctx.result(trees, s.expansions, nil)
+	result.Metrics.Anytime = &s.metrics
+	return result
+}
+
+// depth-first branch and bound over partial trees. the children of a node are
+// tried cheapest bound first, so the first dive finds a good tree fast, and
+// every later tree has to beat the best one so far to be kept
+type anytimeSearch struct {
+	ctx        *searchContext
+	table      *costTable
+	start      time.Time
+	expansions int
+	best       *astarChoice
+	bestSteps  int
+	stopped    bool
+	metrics    AnytimeMetrics
+}
+
+func (s *anytimeSearch) run() (utilities.RecipeTree, bool) {
+	target := s.ctx.req.Target
+	if utilities.IsBaseElement(target) {
+		s.metrics.ProvenOptimal = true
+		return utilities.RecipeTree{Element: target}, true
+	}
+
+	// the minimal steps of an element ignoring the constraints bound the steps
+	// it needs with them
+	stopSetup := s.ctx.stats.phase(PhaseSetup)
+	s.table = newCostTable(nil)
+	root := s.table.costOf(target)
+	stopSetup()
+	if root == unreachableCost {
+		return utilities.RecipeTree{}, false
+	}
+	s.metrics.LowerBound = root
+
+	stopSearch := s.ctx.stats.phase(PhaseSearch)
+	s.ctx.generate(target, 0)
+	s.dive([]string{target}, []int{0}, nil, 0, root)
+	stopSearch()
+
+	if s.best == nil {
+		return utilities.RecipeTree{}, false
+	}
+	s.metrics.Steps = s.bestSteps
+	s.metrics.ProvenOptimal = !s.stopped || s.bestSteps == root
+	return rebuildAStarTree(target, s.best), true
+}
+
+// g crafts are chosen so far and h bounds the crafts the pending elements need.
+// returns false once the search should stop altogether
+func (s *anytimeSearch) dive(pending []string, depths []int, choice *astarChoice, g int, h int) bool {
+	if s.best != nil && g+h >= s.bestSteps {
+		return true
+	}
+	if len(pending) == 0 {
+		s.complete(choice, g)
+		// nothing can beat a tree on the lower bound of the target
+		return s.best == nil || s.bestSteps > s.metrics.LowerBound
+	}
+
+	last := len(pending) - 1
+	element, depth := pending[last], depths[last]
+	if !s.ctx.expand(element, depth) {
+		s.stopped = true
+		return false
+	}
+	s.expansions++
+	s.ctx.stats.frontier(len(pending))
+
+	type child struct {
+		recipe utilities.Recipe
+		h      int
+	}
+	restH := h - s.table.costOf(element)
+	var children []child
+	for _, recipe := range s.ctx.tierValidRecipes(element) {
+		c1, c2 := s.table.costOf(recipe.Element1), s.table.costOf(recipe.Element2)
+		if c1 == unreachableCost || c2 == unreachableCost {
+			continue
+		}
+		children = append(children, child{recipe, restH + c1 + c2})
+	}
+	sort.SliceStable(children, func(i, j int) bool {
+		return children[i].h < children[j].h
+	})
+
+	rest, restDepths := pending[:last], depths[:last]
+	for _, c := range children {
+		next := append([]string{}, rest...)
+		nextDepths := append([]int{}, restDepths...)
+		for _, ing := range []string{c.recipe.Element2, c.recipe.Element1} {
+			s.ctx.generate(ing, depth+1)
+			if !utilities.IsBaseElement(ing) {
+				next = append(next, ing)
+				nextDepths = append(nextDepths, depth+1)
+			}
+		}
+		if !s.dive(next, nextDepths, &astarChoice{recipe: c.recipe, prev: choice}, g+1, c.h) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *anytimeSearch) complete(choice *astarChoice, steps int) {
+	tree := rebuildAStarTree(s.ctx.req.Target, choice)
+	if !s.ctx.acceptsTree(tree) {
+		return
+	}
+	elapsed := float64(time.Since(s.start).Microseconds()) / 1000
+
+	s.best, s.bestSteps = choice, steps
+	s.metrics.Improvements++
+	if s.metrics.Improvements == 1 {
+		s.metrics.FirstMs = elapsed
+	}
+	s.metrics.BestMs = elapsed
+	s.ctx.foundTree(tree)
+
+	if s.ctx.req.OnImprove != nil {
+		s.ctx.req.OnImprove(Improvement{
+			Tree:       tree,
+			Steps:      steps,
+			LowerBound: s.metrics.LowerBound,
+			ElapsedMs:  elapsed,
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package searchalgo
+
+import (
+	"container/heap"
+	"tubes2/utilities"
+)
+
+const (
+	HeuristicTier = "tier"
+	HeuristicCost = "cost"
+)
+
+// safety net for targets whose search space explodes
+const MaxAStarExpansions = 200000
+
+type astarSearcher struct{}
+
+func init() {
+	Register(astarSearcher{})
+}
+
+func (astarSearcher) Name() string {
+	return "astar"
+}
+
+func (astarSearcher) Description() string {
+	return "Best-first A* search over partial recipe trees, guided by element tiers or precomputed minimal costs"
+}
+
+func (astarSearcher) Options() []string {
+	return []string{"multipleRecipes", "recipeCount", "seed", "heuristic"}
+}
+
+func (astarSearcher) Search(req SearchRequest) SearchResult {
+	ctx := newSearchContext(req)
+	trees, expansions := astarSearch(ctx)
+	return ctx.result(trees, expansions, nil)
+}
+
+// recipe picked for the next pending element, linked back to the earlier picks
+type astarChoice struct {
+	recipe utilities.Recipe
+	prev   *astarChoice
+}
+
+// a partial tree. pending elements sit on a stack and the top one is always
+// expanded next, so the choices are made in pre-order and each tree is
+// reached along exactly one path. depths holds the tree depth of each pending
+// element for the stats, needs the waypoints each one still has to contain
+// when the waypoint search uses the node
+type astarNode struct {
+	pending []string
+	depths  []int
+	needs   []uint
+	choice  *astarChoice
+	g, f    int
+	seq     int
+}
+
+type astarQueue []*astarNode
+
+func (q astarQueue) Len() int { return len(q) }
+
+func (q astarQueue) Less(i, j int) bool {
+	if q[i].f != q[j].f {
+		return q[i].f < q[j].f
+	}
+	if q[i].g != q[j].g {
+		return q[i].g > q[j].g
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q astarQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *astarQueue) Push(x any) { *q = append(*q, x.(*astarNode)) }
+
+func (q *astarQueue) Pop() any {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
+
+// lower bound on the crafting steps still needed for an element. with tiers a
+// tier t element needs a chain of at least t-1 crafts down to the base elements,
+// with costs the bound is the exact minimum of the cheapest tree
+func astarHeuristic(ctx *searchContext) func(string) int {
+	if ctx.req.Heuristic == HeuristicCost {
+		table := newCostTable(nil)
+		return func(element string) int {
+			return utilities.Max(table.costOf(element), 0)
+		}
+	}
+	return func(element string) int {
+		if utilities.IsBaseElement(element) {
+			return 0
+		}
+		return utilities.Max(utilities.Tiers[element]-1, 0)
+	}
+}
+
+func astarSearch(ctx *searchContext) ([]utilities.RecipeTree, int) {
+	target, maxRecipes := ctx.req.Target, ctx.req.MaxRecipes
+	if maxRecipes <= 0 {
+		maxRecipes = 1
+	}
+
+	if utilities.IsBaseElement(target) {
+		return []utilities.RecipeTree{{Element: target}}, 0
+	}
+
+	// the cost heuristic fills its table for everything below the target on
+	// the first call
+	stopSetup := ctx.stats.phase(PhaseSetup)
+	h := astarHeuristic(ctx)
+	h(target)
+	stopSetup()
+	defer ctx.stats.phase(PhaseSearch)()
+
+	queue := &astarQueue{}
+	seq := 0
+	heap.Push(queue, &astarNode{pending: []string{target}, depths: []int{0}, f: h(target), seq: seq})
+	ctx.generate(target, 0)
+
+	var results []utilities.RecipeTree
+	expansions := 0
+
+	for queue.Len() > 0 && len(results) < maxRecipes {
+		node := heap.Pop(queue).(*astarNode)
+
+		if len(node.pending) == 0 {
+			tree := rebuildAStarTree(target, node.choice)
+			if ctx.acceptsTree(tree) && addUniqueTree(&results, tree, maxRecipes) {
+				ctx.foundTree(tree)
+			}
+			continue
+		}
+
+		last := len(node.pending) - 1
+		element, depth := node.pending[last], node.depths[last]
+		if expansions >= MaxAStarExpansions || !ctx.expand(element, depth) {
+			// the node popped last is the most promising partial tree
+			if len(results) == 0 {
+				if choice, ok := completeAStarNode(node); ok {
+					tree := rebuildAStarTree(target, choice)
+					if ctx.acceptsTree(tree) && addUniqueTree(&results, tree, maxRecipes) {
+						ctx.foundTree(tree)
+					}
+				}
+			}
+			break
+		}
+		expansions++
+
+		rest, restDepths := node.pending[:last], node.depths[:last]
+		restH := node.f - node.g - h(element)
+
+		for _, recipe := range ctx.tierValidRecipes(element) {
+			pending := append([]string{}, rest...)
+			depths := append([]int{}, restDepths...)
+			childH := restH
+			for _, ing := range []string{recipe.Element2, recipe.Element1} {
+				ctx.generate(ing, depth+1)
+				if !utilities.IsBaseElement(ing) {
+					pending = append(pending, ing)
+					depths = append(depths, depth+1)
+					childH += h(ing)
+				}
+			}
+
+			seq++
+			heap.Push(queue, &astarNode{
+				pending: pending,
+				depths:  depths,
+				choice:  &astarChoice{recipe: recipe, prev: node.choice},
+				g:       node.g + 1,
+				f:       node.g + 1 + childH,
+				seq:     seq,
+			})
+		}
+		// the frontier of A* is the open list of partial trees
+		ctx.stats.frontier(queue.Len())
+	}
+
+	return results, expansions
+}
+
+// finishes a partial tree with the cheapest subtree of every pending element,
+// so a search stopped early still returns a tree. the choices are added in the
+// pre-order rebuildAStarTree replays them
+func completeAStarNode(node *astarNode) (*astarChoice, bool) {
+	table := newCostTable(nil)
+	choice := node.choice
+
+	var add func(element string) bool
+	add = func(element string) bool {
+		if utilities.IsBaseElement(element) {
+			return true
+		}
+		if table.costOf(element) == unreachableCost {
+			return false
+		}
+		recipe := table.choice[element]
+		choice = &astarChoice{recipe: recipe, prev: choice}
+		return add(recipe.Element1) && add(recipe.Element2)
+	}
+	for i := len(node.pending) - 1; i >= 0; i-- {
+		if !add(node.pending[i]) {
+			return nil, false
+		}
+	}
+	return choice, true
+}
+
+// replays the choices in the pre-order they were made
+func rebuildAStarTree(target string, last *astarChoice) utilities.RecipeTree {
+	var choices []utilities.Recipe
+	for c := last; c != nil; c = c.prev {
+		choices = append(choices, c.recipe)
+	}
+	next := len(choices) - 1
+
+	var build func(element string) utilities.RecipeTree
+	build = func(element string) utilities.RecipeTree {
+		tree := utilities.RecipeTree{Element: element}
+		if utilities.IsBaseElement(element) {
+			return tree
+		}
+		recipe := choices[next]
+		next--
+		tree.Ingredients = []utilities.RecipeTree{build(recipe.Element1), build(recipe.Element2)}
+		return tree
+	}
+	return build(target)
+}
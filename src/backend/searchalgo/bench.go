@@ -0,0 +1,200 @@
+package searchalgo
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+	"tubes2/utilities"
+	"tubes2/verify"
+)
+
+// one algorithm run on one target with one recipe count
+type BenchRow struct {
+	Target      string  `json:"target"`
+	Tier        int     `json:"tier"`
+	Algorithm   string  `json:"algorithm"`
+	RecipeCount int     `json:"recipeCount"`
+	Time        float64 `json:"time"`
+	Visited     int     `json:"visited"`
+	Expansions  int     `json:"expansions"`
+	Allocs      uint64  `json:"allocs"`
+	AllocBytes  uint64  `json:"allocBytes"`
+	Results     int     `json:"results"`
+	Valid       bool    `json:"valid"`
+	BudgetHit   string  `json:"budgetHit,omitempty"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// averages of the runs of one algorithm and recipe count on the targets of one tier
+type BenchSummary struct {
+	Tier          int     `json:"tier"`
+	Algorithm     string  `json:"algorithm"`
+	RecipeCount   int     `json:"recipeCount"`
+	Runs          int     `json:"runs"`
+	Found         int     `json:"found"`
+	Invalid       int     `json:"invalid"`
+	AvgTime       float64 `json:"avgTime"`
+	AvgExpansions float64 `json:"avgExpansions"`
+	AvgAllocs     float64 `json:"avgAllocs"`
+}
+
+type BenchReport struct {
+	Rows    []BenchRow     `json:"rows"`
+	Summary []BenchSummary `json:"summary"`
+}
+
+// runs every algorithm on every target with every recipe count, one at a time
+// so the time and allocations of a run are not mixed with another. the
+// allocations are counted for the whole process, which is only the search
+// while the benchmark runs
+func Benchmark(targets []string, algorithms []string, recipeCounts []int) (BenchReport, error) {
+	var searchers []Searcher
+	for _, name := range algorithms {
+		s, ok := Lookup(name)
+		if !ok {
+			return BenchReport{}, fmt.Errorf("unsupported algorithm %q", name)
+		}
+		searchers = append(searchers, s)
+	}
+
+	var report BenchReport
+	for _, target := range targets {
+		for _, s := range searchers {
+			for _, count := range recipeCounts {
+				report.Rows = append(report.Rows, benchOne(s, target, count))
+			}
+		}
+	}
+	report.Summary = summarizeBench(report.Rows)
+	return report, nil
+}
+
+func benchOne(s Searcher, target string, recipeCount int) BenchRow {
+	row := BenchRow{
+		Target:      target,
+		Tier:        utilities.Tiers[target],
+		Algorithm:   s.Name(),
+		RecipeCount: recipeCount,
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	result, err := Run(s, SearchRequest{
+		Target:        target,
+		MaxRecipes:    recipeCount,
+		Deterministic: true,
+	})
+	row.Time = float64(time.Since(start).Microseconds()) / 1000
+	runtime.ReadMemStats(&after)
+
+	row.Allocs = after.Mallocs - before.Mallocs
+	row.AllocBytes = after.TotalAlloc - before.TotalAlloc
+	if err != nil {
+		row.Error = err.Error()
+		return row
+	}
+
+	row.Visited = result.Metrics.NodesVisited
+	row.Expansions = result.Metrics.Stats.Expansions
+	row.Results = len(result.Trees)
+	if result.Metrics.BudgetHit != nil {
+		row.BudgetHit = result.Metrics.BudgetHit.Budget
+	}
+
+	row.Valid = true
+	for _, tree := range result.Trees {
+		if !verify.Tree(tree, verify.Options{}).Valid {
+			row.Valid = false
+		}
+	}
+	return row
+}
+
+func summarizeBench(rows []BenchRow) []BenchSummary {
+	type key struct {
+		tier      int
+		algorithm string
+		count     int
+	}
+	groups := make(map[key]*BenchSummary)
+	var order []key
+
+	for _, row := range rows {
+		k := key{row.Tier, row.Algorithm, row.RecipeCount}
+		sum, ok := groups[k]
+		if !ok {
+			sum = &BenchSummary{Tier: row.Tier, Algorithm: row.Algorithm, RecipeCount: row.RecipeCount}
+			groups[k] = sum
+			order = append(order, k)
+		}
+		sum.Runs++
+		if row.Results > 0 {
+			sum.Found++
+		}
+		if !row.Valid {
+			sum.Invalid++
+		}
+		sum.AvgTime += row.Time
+		sum.AvgExpansions += float64(row.Expansions)
+		sum.AvgAllocs += float64(row.Allocs)
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if a.tier != b.tier {
+			return a.tier < b.tier
+		}
+		if a.algorithm != b.algorithm {
+			return a.algorithm < b.algorithm
+		}
+		return a.count < b.count
+	})
+
+	summary := make([]BenchSummary, 0, len(order))
+	for _, k := range order {
+		sum := *groups[k]
+		runs := float64(sum.Runs)
+		sum.AvgTime /= runs
+		sum.AvgExpansions /= runs
+		sum.AvgAllocs /= runs
+		summary = append(summary, sum)
+	}
+	return summary
+}
+
+func WriteBenchCSV(w io.Writer, rows []BenchRow) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"target", "tier", "algorithm", "recipeCount", "timeMs", "visited", "expansions", "allocs", "allocBytes", "results", "valid", "budgetHit", "error"})
+	for _, row := range rows {
+		out.Write([]string{
+			row.Target,
+			strconv.Itoa(row.Tier),
+			row.Algorithm,
+			strconv.Itoa(row.RecipeCount),
+			strconv.FormatFloat(row.Time, 'f', 3, 64),
+			strconv.Itoa(row.Visited),
+			strconv.Itoa(row.Expansions),
+			strconv.FormatUint(row.Allocs, 10),
+			strconv.FormatUint(row.AllocBytes, 10),
+			strconv.Itoa(row.Results),
+			strconv.FormatBool(row.Valid),
+			row.BudgetHit,
+			row.Error,
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func WriteBenchJSON(w io.Writer, report BenchReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -1,46 +1,90 @@
 package searchalgo
 
 import (
-	"fmt"
 	"sync"
+	"sync/atomic"
 	"tubes2/utilities"
 )
 
+type bfsSearcher struct{}
+
+func init() {
+	Register(bfsSearcher{})
+}
+
+func (bfsSearcher) Name() string {
+	return "bfs"
+}
+
+func (bfsSearcher) Description() string {
+	return "Breadth-first search that resolves the ingredients of each recipe level by level"
+}
+
+func (bfsSearcher) Options() []string {
+	return []string{"multipleRecipes", "recipeCount", "deterministic", "seed", "parallelism", "relaxed"}
+}
+
+func (bfsSearcher) Search(req SearchRequest) SearchResult {
+	return bfsSearch(newSearchContext(req))
+}
+
 func BFSSearch(target string, maxRecipes int) ([]utilities.RecipeTree, int, []utilities.Step) {
+	result := bfsSearch(newSearchContext(SearchRequest{
+		Target:        target,
+		MaxRecipes:    maxRecipes,
+		Deterministic: DefaultDeterministic,
+	}))
+	return result.Trees, result.Metrics.NodesVisited, result.Steps
+}
+
+// outcome of resolving one top-level recipe of the target
+type bfsBranch struct {
+	ctx      *searchContext
+	explored bool
+	ok       bool
+	tree     utilities.RecipeTree
+	visits   int
+	steps    []utilities.Step
+}
+
+func bfsSearch(ctx *searchContext) SearchResult {
+	target, maxRecipes := ctx.req.Target, ctx.req.MaxRecipes
 	visited := 0
 	var liveSteps []utilities.Step
 
 	if utilities.IsBaseElement(target) {
 		// Target is base, count as visited
 		tree := utilities.RecipeTree{Element: target}
-		return []utilities.RecipeTree{tree}, 1, liveSteps
+		return ctx.result([]utilities.RecipeTree{tree}, 1, liveSteps)
 	}
 
-	recipeList, exists := utilities.Recipes[target]
-	if !exists || len(recipeList) == 0 {
-		fmt.Printf("Target element '%s' doesn't exist or can't be created\n", target)
-		return nil, visited, liveSteps
+	recipeList := ctx.recipesFor(target)
+	if len(recipeList) == 0 {
+		return ctx.result(nil, visited, liveSteps)
 	}
 
-	targetTier, targetTierExists := utilities.Tiers[target]
-	if !targetTierExists {
-		fmt.Printf("Target element '%s' does not have a valid tier\n", target)
-		return nil, visited, liveSteps
+	if _, targetTierExists := utilities.Tiers[target]; !targetTierExists {
+		return ctx.result(nil, visited, liveSteps)
 	}
 
+	stopSearch := ctx.stats.phase(PhaseSearch)
+	ctx.generate(target, 0)
+	ctx.visit(target, 0)
+
 	var allResults []utilities.RecipeTree
 	foundCount := 0
 
 	if maxRecipes <= 1 {
 		for _, recipe := range recipeList {
-			if maxRecipes > 0 && foundCount >= maxRecipes {
+			if (maxRecipes > 0 && foundCount >= maxRecipes) || ctx.budget.exhausted() {
 				break
 			}
 
 			e1, e2 := recipe.Element1, recipe.Element2
-			e1Tier, ok1 := utilities.Tiers[e1]
-			e2Tier, ok2 := utilities.Tiers[e2]
-			if ok1 && ok2 && (e1Tier >= targetTier || e2Tier >= targetTier) {
+			if !ctx.tierAllows(target, recipe) || ctx.closesCycle(nil, target, e1, e2) {
+				continue
+			}
+			if !ctx.allowsRecipe(recipe) {
 				continue
 			}
 
@@ -48,9 +92,12 @@ func BFSSearch(target string, maxRecipes int) ([]utilities.RecipeTree, int, []ut
 			found[target] = []string{e1, e2}
 
 			visitCount := 0
-			if processRecipe(e1, e2, found, &visitCount, &liveSteps, targetTier, target) {
+			if processRecipe(ctx, e1, e2, found, &visitCount, &liveSteps, target) {
 				visited += visitCount
 				recipeTree := utilities.BuildRecipeTree(target, found)
+				if !ctx.acceptsTree(recipeTree) {
+					continue
+				}
 				allResults = append(allResults, recipeTree)
 				foundCount++
 			} else {
@@ -58,74 +105,158 @@ func BFSSearch(target string, maxRecipes int) ([]utilities.RecipeTree, int, []ut
 			}
 		}
 	} else {
+		branches := make([]bfsBranch, len(recipeList))
+		jobs := make(chan int)
 		var wg sync.WaitGroup
-		var mu sync.Mutex
-		resultCount := 0
+		// lets the workers stop early when the result order does not have to be reproducible
+		var succeeded atomic.Int64
 
-		for _, recipe := range recipeList {
-			if resultCount >= maxRecipes {
+		for w := 0; w < ctx.parallelism(); w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				for idx := range jobs {
+					if (!ctx.req.Deterministic && succeeded.Load() >= int64(maxRecipes)) || ctx.budget.exhausted() {
+						continue
+					}
+
+					// a worker only ever touches the branch it is given, so the
+					// visit count and step buffer need no locking
+					branch := &branches[idx]
+					e1 := recipeList[idx].Element1
+					e2 := recipeList[idx].Element2
+
+					found := make(map[string][]string)
+					found[target] = []string{e1, e2}
+
+					branch.ctx = ctx.branch()
+					branch.explored = true
+					branch.ok = processRecipe(branch.ctx, e1, e2, found, &branch.visits, &branch.steps, target)
+					if branch.ok {
+						branch.tree = utilities.BuildRecipeTree(target, found)
+						branch.ok = branch.ctx.acceptsTree(branch.tree)
+					}
+					if branch.ok {
+						succeeded.Add(1)
+					}
+				}
+			}()
+		}
+
+		for i, recipe := range recipeList {
+			if (!ctx.req.Deterministic && succeeded.Load() >= int64(maxRecipes)) || ctx.budget.exhausted() {
 				break
 			}
 
 			e1, e2 := recipe.Element1, recipe.Element2
-			e1Tier, ok1 := utilities.Tiers[e1]
-			e2Tier, ok2 := utilities.Tiers[e2]
-			if ok1 && ok2 && (e1Tier >= targetTier || e2Tier >= targetTier) {
+			if !ctx.tierAllows(target, recipe) || ctx.closesCycle(nil, target, e1, e2) {
+				continue
+			}
+			if !ctx.allowsRecipe(recipe) {
 				continue
 			}
 
-			wg.Add(1)
-			go func(r utilities.Recipe) {
-				defer wg.Done()
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
 
-				mu.Lock()
-				if resultCount >= maxRecipes {
-					mu.Unlock()
-					return
-				}
-				mu.Unlock()
+		// merge the branches in recipe order. deterministic searches stop where a
+		// sequential search would have, the others keep the work every worker did
+		resultCount := 0
+		for _, branch := range branches {
+			if ctx.req.Deterministic && resultCount >= maxRecipes {
+				break
+			}
+			if !branch.explored {
+				continue
+			}
 
-				e1 := r.Element1
-				e2 := r.Element2
+			visited += branch.visits
+			liveSteps = append(liveSteps, branch.steps...)
+			ctx.join(branch.ctx)
+			if branch.ok && resultCount < maxRecipes {
+				allResults = append(allResults, branch.tree)
+				resultCount++
+			}
+		}
+	}
 
-				found := make(map[string][]string)
-				found[target] = []string{e1, e2}
+	stopSearch()
+	return ctx.result(allResults, visited, liveSteps)
+}
 
-				localVisitCount := 0
-				if processRecipe(e1, e2, found, &localVisitCount, &liveSteps, targetTier, target) {
-					mu.Lock()
-					defer mu.Unlock()
+// the order of a deterministic bfsSearch, one tree at a time. every top-level
+// recipe gives at most one tree, and the next one is only resolved once the
+// tree before it has been handed out
+func (bfsSearcher) enumerate(ctx *searchContext, emit func(tree utilities.RecipeTree, visited int) bool) int {
+	target := ctx.req.Target
+	visited := 0
 
-					if resultCount >= maxRecipes {
-						return
-					}
+	if utilities.IsBaseElement(target) {
+		emit(utilities.RecipeTree{Element: target}, 1)
+		return 1
+	}
 
-					visited += localVisitCount
-					recipeTree := utilities.BuildRecipeTree(target, found)
-					allResults = append(allResults, recipeTree)
-					resultCount++
-				} else {
-					mu.Lock()
-					visited += localVisitCount
-					mu.Unlock()
-				}
-			}(recipe)
-		}
+	recipeList := ctx.recipesFor(target)
+	if len(recipeList) == 0 {
+		return visited
+	}
 
-		wg.Wait()
-		foundCount = resultCount
+	if _, targetTierExists := utilities.Tiers[target]; !targetTierExists {
+		return visited
 	}
 
-	if maxRecipes > 0 && foundCount < maxRecipes {
-		fmt.Printf("Note: Only found %d recipe(s) for '%s' while %d were requested.\n",
-			foundCount, target, maxRecipes)
+	stopSearch := ctx.stats.phase(PhaseSearch)
+	ctx.generate(target, 0)
+	ctx.visit(target, 0)
+
+	for _, recipe := range recipeList {
+		if ctx.budget.exhausted() {
+			break
+		}
+
+		e1, e2 := recipe.Element1, recipe.Element2
+		if !ctx.tierAllows(target, recipe) || ctx.closesCycle(nil, target, e1, e2) {
+			continue
+		}
+		if !ctx.allowsRecipe(recipe) {
+			continue
+		}
+
+		found := make(map[string][]string)
+		found[target] = []string{e1, e2}
+
+		// the steps are only shown for whole searches, a page has none
+		var steps []utilities.Step
+		if !processRecipe(ctx, e1, e2, found, &visited, &steps, target) {
+			continue
+		}
+		recipeTree := utilities.BuildRecipeTree(target, found)
+		if !ctx.acceptsTree(recipeTree) {
+			continue
+		}
+
+		// the time spent waiting for the next page is not part of any phase
+		stopSearch()
+		if !emit(recipeTree, visited) {
+			return visited
+		}
+		stopSearch = ctx.stats.phase(PhaseSearch)
 	}
 
-	return allResults, visited, liveSteps
+	stopSearch()
+	return visited
 }
 
-func processRecipe(e1 string, e2 string, found map[string][]string, visitCount *int, steps *[]utilities.Step, targetTier int, target string) bool {
+func processRecipe(ctx *searchContext, e1 string, e2 string, found map[string][]string, visitCount *int, steps *[]utilities.Step, target string) bool {
 	queue := []string{}
+	// tree depth of every queued element, the target is depth 0
+	depths := map[string]int{e1: 1, e2: 1}
+	ctx.trace.found(utilities.Recipe{Element1: e1, Element2: e2, Result: target}, 0, "")
+	ctx.generate(e1, 1)
+	ctx.generate(e2, 1)
 
 	// Count target as visited
 	*visitCount++
@@ -145,22 +276,26 @@ func processRecipe(e1 string, e2 string, found map[string][]string, visitCount *
 	if len(queue) == 0 {
 		return true
 	}
+	ctx.stats.frontier(len(queue))
 
 	for len(queue) > 0 {
 		element := queue[0]
 		queue = queue[1:]
 
 		if found[element] != nil {
+			ctx.stats.duplicate()
 			continue
 		}
+		if !ctx.expand(element, depths[element]) {
+			return false
+		}
 
-		recipeList, exists := utilities.Recipes[element]
-		if !exists || len(recipeList) == 0 {
+		recipeList := ctx.recipesFor(element)
+		if len(recipeList) == 0 {
 			return false
 		}
 
-		elementTier, tierExists := utilities.Tiers[element]
-		if !tierExists {
+		if _, tierExists := utilities.Tiers[element]; !tierExists {
 			return false
 		}
 
@@ -168,25 +303,36 @@ func processRecipe(e1 string, e2 string, found map[string][]string, visitCount *
 		for _, recipe := range recipeList {
 			ing1 := recipe.Element1
 			ing2 := recipe.Element2
-			ing1Tier, ok1 := utilities.Tiers[ing1]
-			ing2Tier, ok2 := utilities.Tiers[ing2]
 
-			if ok1 && ok2 && (ing1Tier >= elementTier || ing2Tier >= elementTier || ing1Tier >= targetTier || ing2Tier >= targetTier) {
+			if !ctx.tierAllows(element, recipe) || ctx.closesCycle(found, element, ing1, ing2) {
+				continue
+			}
+			if !ctx.allowsRecipe(recipe) {
 				continue
 			}
 
 			found[element] = []string{ing1, ing2}
+			ctx.trace.found(recipe, depths[element], "")
 
 			// Count the current ingredients as visited
 			*visitCount++
 			*visitCount++
 
-			if !utilities.IsBaseElement(ing1) && found[ing1] == nil {
-				queue = append(queue, ing1)
-			}
-			if !utilities.IsBaseElement(ing2) && found[ing2] == nil {
-				queue = append(queue, ing2)
+			for _, ing := range []string{ing1, ing2} {
+				ctx.generate(ing, depths[element]+1)
+				if utilities.IsBaseElement(ing) {
+					continue
+				}
+				if found[ing] != nil {
+					ctx.stats.duplicate()
+					continue
+				}
+				if _, queued := depths[ing]; !queued {
+					depths[ing] = depths[element] + 1
+				}
+				queue = append(queue, ing)
 			}
+			ctx.stats.frontier(len(queue))
 
 			*steps = append(*steps, utilities.Step{
 				Current:  element,
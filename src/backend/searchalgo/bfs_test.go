@@ -0,0 +1,58 @@
+package searchalgo
+
+import (
+	"fmt"
+	"testing"
+	"tubes2/utilities"
+)
+
+var poolTargets = []string{"Brick", "Airplane", "Ambulance", "Aurora", "Astronomer", "Mailbox"}
+
+func TestBFSPoolMatchesSingleWorker(t *testing.T) {
+	for _, target := range poolTargets {
+		target := target
+		t.Run(target, func(t *testing.T) {
+			t.Parallel()
+
+			search := func(workers int) SearchResult {
+				return bfsSearch(newSearchContext(SearchRequest{
+					Target:      target,
+					MaxRecipes:  5,
+					Parallelism: workers,
+				}))
+			}
+
+			want := search(1)
+			for _, workers := range []int{2, 4, 8} {
+				got := search(workers)
+				if len(got.Trees) != len(want.Trees) {
+					t.Fatalf("%d workers found %d trees, 1 worker found %d", workers, len(got.Trees), len(want.Trees))
+				}
+				for i := range want.Trees {
+					if !utilities.IsSameRecipeTree(got.Trees[i], want.Trees[i]) {
+						t.Errorf("%d workers: tree %d differs from the single worker one", workers, i)
+					}
+				}
+				if got.Metrics.NodesVisited != want.Metrics.NodesVisited {
+					t.Errorf("%d workers visited %d nodes, 1 worker visited %d", workers, got.Metrics.NodesVisited, want.Metrics.NodesVisited)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkBFSPool(b *testing.B) {
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, target := range poolTargets {
+					bfsSearch(newSearchContext(SearchRequest{
+						Target:      target,
+						MaxRecipes:  10,
+						Parallelism: workers,
+					}))
+				}
+			}
+		})
+	}
+}
@@ -1,263 +1,357 @@
 package searchalgo
 
 import (
-	"fmt"
 	"sync"
 	"tubes2/utilities"
 )
 
+// rounds each frontier may expand before the search gives up
 const MaxDepth = 40
 
-func BiDirectionalSearch(target string, maxRecipes int) ([]utilities.RecipeTree, int) {
-	counter := &SafeCounter{v: 0}
-	counter.Inc()
+// sides of the search, as named in the trace
+const (
+	directionForward  = "forward"
+	directionBackward = "backward"
+)
 
-	if utilities.IsBaseElement(target) {
-		tree := utilities.RecipeTree{Element: target}
-		return []utilities.RecipeTree{tree}, 0
-	}
+type bidirectionalSearcher struct{}
 
-	recipeList, exists := utilities.Recipes[target]
-	if !exists || len(recipeList) == 0 {
-		fmt.Printf("Target element '%s' doesn't exist or can't be created\n", target)
-		return nil, 0
-	}
+func init() {
+	Register(bidirectionalSearcher{})
+}
 
-	var allResults []utilities.RecipeTree
-	var mutex sync.Mutex
+func (bidirectionalSearcher) Name() string {
+	return "bidirectional"
+}
 
-	forwardQueue := []*utilities.Node{}
-	backwardQueue := []*utilities.Node{}
+func (bidirectionalSearcher) Description() string {
+	return "Searches forward from the target and backward from the base elements until both frontiers meet"
+}
 
-	var forwardVisitedMap sync.Map // map[string]map[string][]string
-	var backwardVisitedMap sync.Map
+func (bidirectionalSearcher) Options() []string {
+	return []string{"multipleRecipes", "recipeCount", "deterministic", "seed", "relaxed"}
+}
 
-	globalForwardVisited := map[string]bool{}
-	globalBackwardVisited := map[string]bool{}
+func (bidirectionalSearcher) Search(req SearchRequest) SearchResult {
+	ctx := newSearchContext(req)
+	b := newBidirectional(ctx)
+	trees := b.search()
 
-	startNode := &utilities.Node{
-		Element:     target,
-		Path:        []string{target},
-		Visited:     map[string]bool{target: true},
-		Depth:       0,
-		Ingredients: map[string][]string{},
+	result := ctx.result(trees, b.forwardStats.NodesVisited+b.backwardStats.NodesVisited, nil)
+	result.Metrics.Forward = &b.forwardStats
+	result.Metrics.Backward = &b.backwardStats
+	return result
+}
+
+func BiDirectionalSearch(target string, maxRecipes int) ([]utilities.RecipeTree, int) {
+	b := newBidirectional(newSearchContext(SearchRequest{
+		Target:        target,
+		MaxRecipes:    maxRecipes,
+		Deterministic: DefaultDeterministic,
+	}))
+	trees := b.search()
+	return trees, b.forwardStats.NodesVisited + b.backwardStats.NodesVisited
+}
+
+// work done by one side of the bidirectional search
+type DirectionMetrics struct {
+	NodesVisited int `json:"nodesVisited"`
+	Expanded     int `json:"expanded"`
+	MaxFrontier  int `json:"maxFrontier"`
+	Rounds       int `json:"rounds"`
+}
+
+// the forward side walks down from the target and records every usable recipe
+// of the elements it meets. the backward side grows the set of elements that
+// can be crafted from the base elements and remembers the recipe that first
+// made each one. both sides only write their own maps, so a round of each can
+// run at the same time and the meeting is checked in between
+type bidirectional struct {
+	ctx *searchContext
+
+	forward      map[string][]utilities.Recipe
+	forwardSeen  map[string]bool
+	forwardStats DirectionMetrics
+
+	backward      map[string]utilities.Recipe
+	known         map[string]bool
+	backwardStats DirectionMetrics
+}
+
+func newBidirectional(ctx *searchContext) *bidirectional {
+	return &bidirectional{
+		ctx:         ctx,
+		forward:     make(map[string][]utilities.Recipe),
+		forwardSeen: make(map[string]bool),
+		backward:    make(map[string]utilities.Recipe),
+		known:       make(map[string]bool),
 	}
-	forwardQueue = append(forwardQueue, startNode)
-	globalForwardVisited[target] = true
+}
 
+func (b *bidirectional) search() []utilities.RecipeTree {
+	target, maxRecipes := b.ctx.req.Target, b.ctx.req.MaxRecipes
+	if maxRecipes <= 0 {
+		maxRecipes = 1
+	}
+
+	if utilities.IsBaseElement(target) {
+		return []utilities.RecipeTree{{Element: target}}
+	}
+	if len(utilities.Recipes[target]) == 0 {
+		return nil
+	}
+
+	forwardFrontier := []string{target}
+	b.forwardSeen[target] = true
+	b.ctx.generateFrom(directionForward, target, 0)
+
+	// the backward side counts its depth up from the base elements
+	var backwardFrontier []string
 	for _, base := range utilities.BaseElements {
-		node := &utilities.Node{
-			Element:     base,
-			Path:        []string{base},
-			Visited:     map[string]bool{base: true},
-			Depth:       0,
-			Ingredients: map[string][]string{},
+		b.known[base] = true
+		backwardFrontier = append(backwardFrontier, base)
+		b.ctx.generateFrom(directionBackward, base, 0)
+	}
+
+	var trees []utilities.RecipeTree
+	for round := 0; round < MaxDepth; round++ {
+		if len(forwardFrontier) == 0 && len(backwardFrontier) == 0 {
+			break
+		}
+
+		stopSearch := b.ctx.stats.phase(PhaseSearch)
+		// each side traces into its own fork, forward first once both are done
+		forward, backward := b.ctx.branch(), b.ctx.branch()
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			forwardFrontier = b.expandForward(forward, forwardFrontier, round)
+		}()
+		go func() {
+			defer wg.Done()
+			backwardFrontier = b.expandBackward(backward, backwardFrontier, round)
+		}()
+		wg.Wait()
+		b.ctx.join(forward, backward)
+		stopSearch()
+
+		// keep going while the frontiers may still turn up the missing trees
+		stopBuild := b.ctx.stats.phase(PhaseBuild)
+		plan := b.plan()
+		trees = b.collect(plan, maxRecipes)
+		stopBuild()
+		if len(trees) >= maxRecipes || (len(forwardFrontier) == 0 && b.covers(plan)) || b.ctx.budget.exhausted() {
+			break
 		}
-		backwardQueue = append(backwardQueue, node)
-		counter.Inc()
-		globalBackwardVisited[base] = true
-		backwardVisitedMap.Store(base, map[string][]string{})
 	}
 
-	recipesFound := 0
+	// the trees are only settled once the frontiers stop, so they are traced here
+	for _, tree := range trees {
+		b.ctx.foundTree(tree)
+	}
+	return trees
+}
 
-	for len(forwardQueue) > 0 && len(backwardQueue) > 0 && (maxRecipes <= 0 || recipesFound < maxRecipes) {
-		// Forward phase
-		fq := forwardQueue
-		forwardQueue = []*utilities.Node{}
-		for _, node := range fq {
-			if node.Depth >= MaxDepth {
+// expands one level below the target, the frontier holds the elements at depth
+func (b *bidirectional) expandForward(ctx *searchContext, frontier []string, depth int) []string {
+	if len(frontier) == 0 {
+		return nil
+	}
+	b.forwardStats.Rounds++
+	b.forwardStats.MaxFrontier = utilities.Max(b.forwardStats.MaxFrontier, len(frontier))
+	ctx.stats.frontier(len(frontier))
+
+	var next []string
+	for _, element := range frontier {
+		if !ctx.expandFrom(directionForward, element, depth) {
+			break
+		}
+		b.forwardStats.Expanded++
+		seen := make(map[[2]string]bool)
+
+		for _, recipe := range ctx.recipesFor(element) {
+			b.forwardStats.NodesVisited++
+			e1, e2 := recipe.Element1, recipe.Element2
+			key := utilities.PairKey(e1, e2)
+			if seen[key] {
+				ctx.stats.duplicate()
+				continue
+			}
+			if !ctx.tierAllows(element, recipe) || !ctx.allowsRecipe(recipe) || ctx.closesCycle(nil, element, e1, e2) {
 				continue
 			}
-			if recipes, ok := utilities.Recipes[node.Element]; ok {
-				for _, recipe := range recipes {
-					counter.Inc()
-					e1, e2 := recipe.Element1, recipe.Element2
-
-					v, _ := forwardVisitedMap.LoadOrStore(node.Element, map[string][]string{})
-					elemMap := v.(map[string][]string)
-					elemMap[fmt.Sprintf("%s+%s", e1, e2)] = []string{e1, e2}
-					forwardVisitedMap.Store(node.Element, elemMap)
-
-					utilities.TrackLiveUpdate(node.Element, node.Path, map[string][]string{node.Element: {e1, e2}})
-
-					if hasInMap(&backwardVisitedMap, e1) || hasInMap(&backwardVisitedMap, e2) {
-						complete := buildCompleteRecipe(target, []string{e1, e2}, e1, e2, mapFromSync(&forwardVisitedMap), mapFromSync(&backwardVisitedMap))
-						tree := utilities.BuildRecipeTree(target, complete)
-
-						mutex.Lock()
-						allResults = append(allResults, tree)
-						recipesFound++
-						mutex.Unlock()
-
-						if maxRecipes > 0 && recipesFound >= maxRecipes {
-							return allResults, counter.Value()
-						}
-					}
-
-					for _, elem := range []string{e1, e2} {
-						if !globalForwardVisited[elem] && !utilities.IsBaseElement(elem) {
-							globalForwardVisited[elem] = true
-							counter.Inc()
-							forwardQueue = append(forwardQueue, &utilities.Node{
-								Element:     elem,
-								Path:        append(copySlice(node.Path), elem),
-								Depth:       node.Depth + 1,
-								Ingredients: copyIngredients(node.Ingredients),
-							})
-						}
-					}
+			seen[key] = true
+			b.forward[element] = append(b.forward[element], recipe)
+
+			for _, ing := range []string{e1, e2} {
+				ctx.generateFrom(directionForward, ing, depth+1)
+				if utilities.IsBaseElement(ing) {
+					continue
+				}
+				if b.forwardSeen[ing] {
+					ctx.stats.duplicate()
+					continue
 				}
+				b.forwardSeen[ing] = true
+				next = append(next, ing)
 			}
 		}
+	}
+	return next
+}
+
+// adds every element that can be crafted from the elements known so far, the
+// frontier holds the elements made depth rounds after the base elements
+func (b *bidirectional) expandBackward(ctx *searchContext, frontier []string, depth int) []string {
+	if len(frontier) == 0 {
+		return nil
+	}
+	b.backwardStats.Rounds++
+	b.backwardStats.MaxFrontier = utilities.Max(b.backwardStats.MaxFrontier, len(frontier))
+	ctx.stats.frontier(len(frontier))
+
+	// elements made this round are only usable from the next one on, which keeps
+	// the remembered recipes layered and therefore free of cycles
+	var next []string
+	made := make(map[string]bool)
+	for _, element := range frontier {
+		if !ctx.expandFrom(directionBackward, element, depth) {
+			break
+		}
+		b.backwardStats.Expanded++
 
-		// Backward phase
-		bq := backwardQueue
-		backwardQueue = []*utilities.Node{}
-		for _, node := range bq {
-			if node.Depth >= MaxDepth {
+		for _, recipe := range utilities.FindUses(element) {
+			b.backwardStats.NodesVisited++
+			result := recipe.Result
+			if b.known[result] || made[result] {
+				ctx.stats.duplicate()
 				continue
 			}
-			for result, recipes := range utilities.Recipes {
-				for _, recipe := range recipes {
-					counter.Inc()
-					if recipe.Element1 != node.Element && recipe.Element2 != node.Element {
-						continue
-					}
-					other := recipe.Element2
-					if recipe.Element2 == node.Element {
-						other = recipe.Element1
-					}
-					if globalBackwardVisited[result] {
-						continue
-					}
-					globalBackwardVisited[result] = true
-
-					newIng := copyIngredients(node.Ingredients)
-					newIng[result] = []string{node.Element, other}
-
-					v, _ := backwardVisitedMap.LoadOrStore(result, map[string][]string{})
-					elemMap := v.(map[string][]string)
-					elemMap[fmt.Sprintf("%s+%s", node.Element, other)] = []string{node.Element, other}
-					backwardVisitedMap.Store(result, elemMap)
-
-					utilities.TrackLiveUpdate(result, append(copySlice(node.Path), result), map[string][]string{result: {node.Element, other}})
-
-					if hasInMap(&forwardVisitedMap, result) {
-						complete := buildCompleteRecipe(target, []string{recipe.Element1, recipe.Element2}, node.Element, other, mapFromSync(&forwardVisitedMap), mapFromSync(&backwardVisitedMap))
-						tree := utilities.BuildRecipeTree(target, complete)
-
-						mutex.Lock()
-						allResults = append(allResults, tree)
-						recipesFound++
-						mutex.Unlock()
-
-						if maxRecipes > 0 && recipesFound >= maxRecipes {
-							return allResults, counter.Value()
-						}
-					}
-
-					if !globalBackwardVisited[other] && !utilities.IsBaseElement(other) {
-						globalBackwardVisited[other] = true
-						counter.Inc()
-						backwardQueue = append(backwardQueue, &utilities.Node{
-							Element:     other,
-							Path:        append(copySlice(node.Path), result),
-							Depth:       node.Depth + 1,
-							Ingredients: newIng,
-						})
-					}
-				}
+			if !b.known[recipe.Element1] || !b.known[recipe.Element2] {
+				continue
 			}
+			if !ctx.tierAllows(result, recipe) || !ctx.allowsRecipe(recipe) {
+				continue
+			}
+
+			made[result] = true
+			b.backward[result] = recipe
+			next = append(next, result)
+			ctx.trace.found(recipe, depth+1, directionBackward)
+			ctx.generateFrom(directionBackward, result, depth+1)
 		}
 	}
 
-	return allResults, counter.Value()
+	for _, element := range next {
+		b.known[element] = true
+	}
+	return next
 }
 
-func buildCompleteRecipe(
-	target string,
-	forwardIngredients []string,
-	backwardE1, backwardE2 string,
-	forwardVisited, backwardVisited map[string]map[string][]string,
-) map[string][]string {
-	complete := map[string][]string{}
-	complete[target] = forwardIngredients
-
-	var expand func(string)
-	expand = func(elem string) {
-		if _, exists := complete[elem]; exists || utilities.IsBaseElement(elem) {
-			return
-		}
+// picks one recipe for every element that can currently be built: the recipe
+// the backward side found, or for elements only the forward side has seen the
+// first recipe whose ingredients can already be built. this is where the two
+// frontiers meet
+func (b *bidirectional) plan() map[string]utilities.Recipe {
+	plan := make(map[string]utilities.Recipe)
+	for element, recipe := range b.backward {
+		plan[element] = recipe
+	}
+	buildable := func(element string) bool {
+		_, ok := plan[element]
+		return ok || utilities.IsBaseElement(element)
+	}
 
-		var ingredients []string
-		if m, ok := backwardVisited[elem]; ok {
-			for _, v := range m {
-				ingredients = v
-				break
+	// sweep the forward elements in a fixed order until nothing changes, an
+	// element is only planned after its ingredients so the plan stays acyclic
+	order := utilities.ResultElements
+	for changed := true; changed; {
+		changed = false
+		for _, element := range order {
+			if buildable(element) {
+				continue
 			}
-		}
-		if len(ingredients) == 0 {
-			if m, ok := forwardVisited[elem]; ok {
-				for _, v := range m {
-					ingredients = v
+			for _, recipe := range b.forward[element] {
+				if buildable(recipe.Element1) && buildable(recipe.Element2) {
+					plan[element] = recipe
+					changed = true
 					break
 				}
 			}
 		}
+	}
+	return plan
+}
 
-		if len(ingredients) == 2 {
-			complete[elem] = ingredients
-			expand(ingredients[0])
-			expand(ingredients[1])
-		} else {
-			if recipes, ok := utilities.Recipes[elem]; ok {
-				for _, r := range recipes {
-					ingredients = []string{r.Element1, r.Element2}
-					complete[elem] = ingredients
-					expand(ingredients[0])
-					expand(ingredients[1])
-					break
-				}
-			} else {
-				fmt.Printf("Warning: Could not find ingredients for element %s\n", elem)
-			}
+// whether every element the forward side reached can already be built, in which
+// case further backward rounds cannot add trees
+func (b *bidirectional) covers(plan map[string]utilities.Recipe) bool {
+	for element := range b.forward {
+		if _, ok := plan[element]; !ok {
+			return false
 		}
 	}
+	return true
+}
+
+// builds distinct trees for the target: first one per top-level recipe, then
+// variations that swap the recipe of one direct ingredient
+func (b *bidirectional) collect(plan map[string]utilities.Recipe, maxRecipes int) []utilities.RecipeTree {
+	target := b.ctx.req.Target
 
-	for _, ing := range forwardIngredients {
-		expand(ing)
+	buildable := func(element string) bool {
+		_, ok := plan[element]
+		return ok || utilities.IsBaseElement(element)
 	}
 
-	return complete
-}
+	var results []utilities.RecipeTree
+	try := func(overrides map[string]utilities.Recipe) {
+		found := make(map[string][]string)
+		for element, recipe := range plan {
+			found[element] = []string{recipe.Element1, recipe.Element2}
+		}
+		for element, recipe := range overrides {
+			found[element] = []string{recipe.Element1, recipe.Element2}
+		}
+		for element := range overrides {
+			if createsCycle(found, element, found[element]...) {
+				return
+			}
+		}
 
-func hasInMap(m *sync.Map, key string) bool {
-	_, ok := m.Load(key)
-	return ok
-}
+		tree := utilities.BuildRecipeTree(target, found)
+		if b.ctx.acceptsTree(tree) {
+			addUniqueTree(&results, tree, maxRecipes)
+		}
+	}
 
-func mapFromSync(m *sync.Map) map[string]map[string][]string {
-	result := make(map[string]map[string][]string)
-	m.Range(func(key, value any) bool {
-		k := key.(string)
-		v := value.(map[string][]string)
-		result[k] = v
-		return true
-	})
-	return result
-}
+	var tops []utilities.Recipe
+	for _, recipe := range b.forward[target] {
+		if buildable(recipe.Element1) && buildable(recipe.Element2) {
+			tops = append(tops, recipe)
+		}
+	}
 
-func copySlice(slice []string) []string {
-	result := make([]string, len(slice))
-	copy(result, slice)
-	return result
-}
+	for _, top := range tops {
+		if len(results) >= maxRecipes {
+			return results
+		}
+		try(map[string]utilities.Recipe{target: top})
+	}
 
-func copyIngredients(original map[string][]string) map[string][]string {
-	copyMap := make(map[string][]string)
-	for k, v := range original {
-		copyMap[k] = append([]string{}, v...)
+	for _, top := range tops {
+		for _, ing := range []string{top.Element1, top.Element2} {
+			for _, alt := range b.forward[ing] {
+				if len(results) >= maxRecipes {
+					return results
+				}
+				if alt == plan[ing] || !buildable(alt.Element1) || !buildable(alt.Element2) {
+					continue
+				}
+				try(map[string]utilities.Recipe{target: top, ing: alt})
+			}
+		}
 	}
-	return copyMap
+	return results
 }
@@ -0,0 +1,157 @@
+package searchalgo
+
+import (
+	"fmt"
+	"runtime/metrics"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	BudgetNodes  = "nodes"
+	BudgetTime   = "time"
+	BudgetMemory = "memory"
+)
+
+// the heap is only sampled every this many checks, reading runtime metrics is
+// far more expensive than visiting a node
+const memoryCheckInterval = 4096
+
+// limits a single search may use, zero values mean no limit
+type Budget struct {
+	MaxNodes    int `json:"maxNodes,omitempty"`
+	MaxTimeMs   int `json:"maxTimeMs,omitempty"`
+	MaxMemoryMB int `json:"maxMemoryMB,omitempty"`
+}
+
+// limits applied to every search, set once from the command line. a request
+// can only tighten them
+var ServerBudget Budget
+
+func (b Budget) validate() error {
+	if b.MaxNodes < 0 || b.MaxTimeMs < 0 || b.MaxMemoryMB < 0 {
+		return fmt.Errorf("budget limits cannot be negative")
+	}
+	return nil
+}
+
+// the stricter of both limits for every field
+func (b Budget) within(limit Budget) Budget {
+	tighter := func(a, b int) int {
+		if a == 0 || (b != 0 && b < a) {
+			return b
+		}
+		return a
+	}
+	return Budget{
+		MaxNodes:    tighter(b.MaxNodes, limit.MaxNodes),
+		MaxTimeMs:   tighter(b.MaxTimeMs, limit.MaxTimeMs),
+		MaxMemoryMB: tighter(b.MaxMemoryMB, limit.MaxMemoryMB),
+	}
+}
+
+// fills the fields b leaves unlimited from defaults
+func (b Budget) withDefaults(defaults Budget) Budget {
+	fill := func(a, d int) int {
+		if a == 0 {
+			return d
+		}
+		return a
+	}
+	return Budget{
+		MaxNodes:    fill(b.MaxNodes, defaults.MaxNodes),
+		MaxTimeMs:   fill(b.MaxTimeMs, defaults.MaxTimeMs),
+		MaxMemoryMB: fill(b.MaxMemoryMB, defaults.MaxMemoryMB),
+	}
+}
+
+// implemented by algorithms that only stop on a budget, the defaults apply to
+// the limits a request leaves out, before the server limits
+type budgetDefaulter interface {
+	defaultBudget() Budget
+}
+
+// which budget stopped a search and why
+type BudgetHit struct {
+	Budget string `json:"budget"`
+	Reason string `json:"reason"`
+}
+
+// tracks what a search has used so far. once a budget runs out every later
+// spend fails, so the algorithms unwind and return what they already found
+type budgetTracker struct {
+	limit    Budget
+	deadline time.Time
+	nodes    SafeCounter
+	checks   atomic.Int64
+	hit      atomic.Pointer[BudgetHit]
+}
+
+func newBudgetTracker(limit Budget) *budgetTracker {
+	t := &budgetTracker{limit: limit}
+	if limit.MaxTimeMs > 0 {
+		t.deadline = time.Now().Add(time.Duration(limit.MaxTimeMs) * time.Millisecond)
+	}
+	return t
+}
+
+// counts one visited node and reports whether the search may go on
+func (t *budgetTracker) spend() bool {
+	if t.exhausted() {
+		return false
+	}
+	t.nodes.Inc()
+	if t.limit.MaxNodes > 0 && t.nodes.Value() > t.limit.MaxNodes {
+		t.stop(BudgetNodes, fmt.Sprintf("visited more than %d nodes", t.limit.MaxNodes))
+		return false
+	}
+	return t.check()
+}
+
+// checks the time and memory budgets without counting a node, for work that
+// is expensive without visiting anything new
+func (t *budgetTracker) check() bool {
+	if t.exhausted() {
+		return false
+	}
+	if t.pastDeadline() {
+		return false
+	}
+	if t.limit.MaxMemoryMB > 0 && t.checks.Add(1)%memoryCheckInterval == 0 {
+		if heap := heapBytes(); heap > uint64(t.limit.MaxMemoryMB)<<20 {
+			t.stop(BudgetMemory, fmt.Sprintf("heap grew to %d MB, above the %d MB limit", heap>>20, t.limit.MaxMemoryMB))
+			return false
+		}
+	}
+	return true
+}
+
+// only looks at the clock, so results found before another budget ran out can
+// still be turned into trees
+func (t *budgetTracker) pastDeadline() bool {
+	if t.deadline.IsZero() || time.Now().Before(t.deadline) {
+		return false
+	}
+	t.stop(BudgetTime, fmt.Sprintf("ran longer than %d ms", t.limit.MaxTimeMs))
+	return true
+}
+
+// only the first budget to run out is reported
+func (t *budgetTracker) stop(budget, reason string) {
+	t.hit.CompareAndSwap(nil, &BudgetHit{Budget: budget, Reason: reason})
+}
+
+func (t *budgetTracker) exhausted() bool {
+	return t.hit.Load() != nil
+}
+
+// live heap of the whole process. searches share it, so the memory budget is
+// an approximate ceiling rather than an exact per-search figure
+func heapBytes() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
@@ -0,0 +1,33 @@
+package searchalgo
+
+import (
+	"testing"
+	"time"
+)
+
+// how far past its time budget a search may run while it unwinds and builds
+// the trees it holds
+const budgetOvershoot = 250 * time.Millisecond
+
+func TestTimeBudgetBoundsEverySearch(t *testing.T) {
+	const maxTimeMs = 200
+	for _, s := range Searchers() {
+		t.Run(s.Name(), func(t *testing.T) {
+			start := time.Now()
+			result, err := Run(s, SearchRequest{
+				Target:     "Mailbox",
+				MaxRecipes: 100000,
+				Relaxed:    containsString(s.Options(), "relaxed"),
+				Budget:     Budget{MaxTimeMs: maxTimeMs},
+			})
+			elapsed := time.Since(start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if elapsed > maxTimeMs*time.Millisecond+budgetOvershoot {
+				t.Errorf("ran %v with a %d ms budget", elapsed, maxTimeMs)
+			}
+			t.Logf("%v, %d trees, budget hit %v", elapsed, len(result.Trees), result.Metrics.BudgetHit)
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package searchalgo
+
+import (
+	"container/list"
+	"encoding/json"
+	"sort"
+	"sync"
+	"tubes2/utilities"
+)
+
+const DefaultCacheSize = 256
+
+// cache used by RunCached, replaced from the command line
+var Cache = NewResultCache(DefaultCacheSize)
+
+func init() {
+	utilities.OnDatasetChange(func() {
+		Cache.Purge()
+	})
+}
+
+// how the cache answered one request, together with its totals so far
+type CacheMetrics struct {
+	Hit      bool  `json:"hit"`
+	Hits     int64 `json:"hits"`
+	Misses   int64 `json:"misses"`
+	Size     int   `json:"size"`
+	Capacity int   `json:"capacity"`
+}
+
+// a finished search together with its trace, so a hit can replay the live steps
+type cacheEntry struct {
+	key     string
+	result  SearchResult
+	events  []TraceEvent
+	dropped int
+}
+
+// least recently used cache of search results. the key holds the dataset
+// version, so a result is never served for another recipe book even before
+// Purge runs
+type ResultCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	items    map[string]*list.Element
+	hits     int64
+	misses   int64
+}
+
+// a capacity of zero or less disables the cache
+func NewResultCache(capacity int) *ResultCache {
+	return &ResultCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *ResultCache) get(key string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.hits++
+		c.order.MoveToFront(el)
+		return el.Value.(cacheEntry), true
+	}
+	c.misses++
+	return cacheEntry{}, false
+}
+
+func (c *ResultCache) put(entry cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.capacity <= 0 {
+		return
+	}
+	if el, ok := c.items[entry.key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[entry.key] = c.order.PushFront(entry)
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(cacheEntry).key)
+	}
+}
+
+func (c *ResultCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+func (c *ResultCache) metrics(hit bool) *CacheMetrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return &CacheMetrics{
+		Hit:      hit,
+		Hits:     c.hits,
+		Misses:   c.misses,
+		Size:     c.order.Len(),
+		Capacity: c.capacity,
+	}
+}
+
+// the fields of a request that change its result, with every list that is
+// really a set sorted so equal requests give equal keys
+type cacheKey struct {
+	Version       string
+	Algorithm     string
+	Target        string
+	MaxRecipes    int
+	StartElements []string
+	Deterministic bool
+	Seed          int64
+	Parallelism   int
+	SortBy        string
+	Costs         map[string]float64
+	Diverse       bool
+	CandidatePool int
+	Constraints   Constraints
+	Waypoints     []string
+	Heuristic     string
+	Relaxed       bool
+	Budget        Budget
+	TraceLimit    int
+}
+
+func requestKey(s Searcher, req SearchRequest) string {
+	key := cacheKey{
+		Version:       utilities.DatasetVersion(),
+		Algorithm:     s.Name(),
+		Target:        req.Target,
+		MaxRecipes:    req.MaxRecipes,
+		StartElements: sortedCopy(req.StartElements),
+		Deterministic: req.Deterministic,
+		Seed:          req.Seed,
+		Parallelism:   req.Parallelism,
+		SortBy:        req.SortBy,
+		Costs:         req.Costs,
+		Diverse:       req.Diverse,
+		CandidatePool: req.CandidatePool,
+		Constraints:   req.Constraints,
+		Waypoints:     sortedCopy(req.Waypoints),
+		Heuristic:     req.Heuristic,
+		Relaxed:       req.Relaxed,
+		Budget:        req.Budget,
+	}
+	key.Constraints.Exclude = sortedCopy(req.Constraints.Exclude)
+	key.Constraints.Require = sortedCopy(req.Constraints.Require)
+	if req.Trace != nil {
+		key.TraceLimit = req.Trace.limit
+	}
+
+	// maps are encoded with sorted keys, so the encoding is stable
+	data, _ := json.Marshal(key)
+	return string(data)
+}
+
+func sortedCopy(list []string) []string {
+	sorted := append([]string{}, list...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// Run through Cache. a hit replays the stored trace into req.Trace. results
+// drawn with a fresh random seed are not stored, asking again has to give a
+// new draw, and neither are results cut short by a budget, which depend on
+// how fast the server was
+func RunCached(s Searcher, req SearchRequest) (SearchResult, error) {
+	key := requestKey(s, req)
+	if entry, ok := Cache.get(key); ok {
+		if req.Trace != nil {
+			req.Trace.replay(entry.events, entry.dropped)
+		}
+		result := entry.result
+		result.Metrics.Cache = Cache.metrics(true)
+		return result, nil
+	}
+
+	trace := req.Trace
+	if trace == nil {
+		trace = NewTracer(0)
+		req.Trace = trace
+	}
+	result, err := Run(s, req)
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	freshSeed := req.Seed == 0 && result.Metrics.Seed != 0
+	if !freshSeed && result.Metrics.BudgetHit == nil {
+		Cache.put(cacheEntry{
+			key:     key,
+			result:  result,
+			events:  trace.Events(),
+			dropped: trace.Dropped(),
+		})
+	}
+	result.Metrics.Cache = Cache.metrics(false)
+	return result, nil
+}
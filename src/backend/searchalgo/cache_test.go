@@ -0,0 +1,39 @@
+package searchalgo
+
+import (
+	"testing"
+)
+
+func TestCacheDropsResultsOfAnotherDataset(t *testing.T) {
+	saved := Cache
+	Cache = NewResultCache(8)
+	t.Cleanup(func() {
+		Cache = saved
+	})
+
+	bfs, _ := Lookup("bfs")
+	req := SearchRequest{Target: "Airplane", MaxRecipes: 2}
+	run := func() *CacheMetrics {
+		result, err := RunCached(bfs, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Metrics.Cache
+	}
+
+	if run().Hit {
+		t.Fatal("the first request was a hit")
+	}
+	if !run().Hit {
+		t.Fatal("the repeated request missed")
+	}
+
+	loadChangedRecipes(t)
+
+	if size := Cache.metrics(false).Size; size != 0 {
+		t.Errorf("%d results survived the dataset change", size)
+	}
+	if run().Hit {
+		t.Error("a result of the old dataset was served")
+	}
+}
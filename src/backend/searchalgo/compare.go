@@ -0,0 +1,61 @@
+package searchalgo
+
+import (
+	"fmt"
+	"time"
+	"tubes2/utilities"
+)
+
+// one algorithm run on one target
+type ComparisonRow struct {
+	Target     string  `json:"target"`
+	Tier       int     `json:"tier"`
+	Algorithm  string  `json:"algorithm"`
+	Expansions int     `json:"expansions"`
+	Recipes    int     `json:"recipes"`
+	BestSteps  int     `json:"bestSteps"`
+	Time       float64 `json:"time"`
+}
+
+// runs every algorithm on every target with the same request so the number of
+// expanded nodes can be compared side by side
+func Compare(targets []string, algorithms []string, maxRecipes int) ([]ComparisonRow, error) {
+	var searchers []Searcher
+	for _, name := range algorithms {
+		s, ok := Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("unsupported algorithm %q", name)
+		}
+		searchers = append(searchers, s)
+	}
+
+	var rows []ComparisonRow
+	for _, target := range targets {
+		for _, s := range searchers {
+			start := time.Now()
+			result, err := Run(s, SearchRequest{
+				Target:        target,
+				MaxRecipes:    maxRecipes,
+				Deterministic: true,
+				SortBy:        SortBySteps,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			row := ComparisonRow{
+				Target:     target,
+				Tier:       utilities.Tiers[target],
+				Algorithm:  s.Name(),
+				Expansions: result.Metrics.Stats.Expansions,
+				Recipes:    len(result.Trees),
+				Time:       float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if len(result.Scores) > 0 {
+				row.BestSteps = result.Scores[0].Steps
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
@@ -0,0 +1,110 @@
+package searchalgo
+
+import (
+	"fmt"
+	"tubes2/utilities"
+)
+
+// limits every algorithm honors while building recipe trees, zero values mean no limit
+type Constraints struct {
+	Exclude  []string `json:"exclude,omitempty"`
+	Require  []string `json:"require,omitempty"`
+	MaxDepth int      `json:"maxDepth,omitempty"`
+	MaxTier  int      `json:"maxTier,omitempty"`
+	MaxSteps int      `json:"maxSteps,omitempty"`
+}
+
+func (c Constraints) empty() bool {
+	return len(c.Exclude) == 0 && len(c.Require) == 0 &&
+		c.MaxDepth == 0 && c.MaxTier == 0 && c.MaxSteps == 0
+}
+
+func (c Constraints) validate() error {
+	if c.MaxDepth < 0 || c.MaxTier < 0 || c.MaxSteps < 0 {
+		return fmt.Errorf("constraint limits cannot be negative")
+	}
+	for _, elem := range append(append([]string{}, c.Exclude...), c.Require...) {
+		if !utilities.ElementExists(elem) {
+			return fmt.Errorf("unknown element %q in constraints", elem)
+		}
+	}
+	for _, req := range c.Require {
+		for _, ex := range c.Exclude {
+			if req == ex {
+				return fmt.Errorf("element %q is both required and excluded", req)
+			}
+		}
+	}
+	return nil
+}
+
+// cheap check done before a recipe is explored, so branches that can never
+// satisfy the constraints are cut early
+func (ctx *searchContext) allowsRecipe(recipe utilities.Recipe) bool {
+	if ctx.req.Constraints.empty() {
+		return true
+	}
+	if ctx.allowsIngredient(recipe.Element1) && ctx.allowsIngredient(recipe.Element2) {
+		return true
+	}
+	ctx.pruned.Add(1)
+	ctx.trace.prune(recipe.Result, &recipe, PruneConstraint)
+	return false
+}
+
+func (ctx *searchContext) allowsIngredient(element string) bool {
+	if ctx.excluded[element] {
+		return false
+	}
+	maxTier := ctx.req.Constraints.MaxTier
+	if maxTier > 0 && !utilities.IsBaseElement(element) && utilities.Tiers[element] > maxTier {
+		return false
+	}
+	return true
+}
+
+// full check of a finished tree, covering the constraints that only make
+// sense once the whole tree is known
+func (ctx *searchContext) acceptsTree(tree utilities.RecipeTree) bool {
+	c := ctx.req.Constraints
+	if c.empty() {
+		return true
+	}
+
+	seen := make(map[string]bool)
+	steps := 0
+	allowed := true
+
+	var walk func(node utilities.RecipeTree)
+	walk = func(node utilities.RecipeTree) {
+		seen[node.Element] = true
+		if node.Element != tree.Element && !ctx.allowsIngredient(node.Element) {
+			allowed = false
+		}
+		if len(node.Ingredients) > 0 {
+			steps++
+		}
+		for _, ing := range node.Ingredients {
+			walk(ing)
+		}
+	}
+	walk(tree)
+
+	for _, elem := range c.Require {
+		if !seen[elem] {
+			allowed = false
+		}
+	}
+	if c.MaxSteps > 0 && steps > c.MaxSteps {
+		allowed = false
+	}
+	if c.MaxDepth > 0 && utilities.CalculateTreeDepth(tree) > c.MaxDepth {
+		allowed = false
+	}
+
+	if !allowed {
+		ctx.pruned.Add(1)
+		ctx.trace.prune(tree.Element, nil, PruneConstraint)
+	}
+	return allowed
+}
@@ -0,0 +1,53 @@
+package searchalgo
+
+import (
+	"testing"
+	"tubes2/utilities"
+)
+
+func treeHas(tree utilities.RecipeTree, element string) bool {
+	if tree.Element == element {
+		return true
+	}
+	for _, ing := range tree.Ingredients {
+		if treeHas(ing, element) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestConstraintsHoldForEveryAlgorithm(t *testing.T) {
+	// Airplane is Bird + Metal or Bird + Steel, so both constraints leave trees
+	cases := []struct {
+		name        string
+		constraints Constraints
+		want        bool
+	}{
+		{"require", Constraints{Require: []string{"Steel"}}, true},
+		{"exclude", Constraints{Exclude: []string{"Steel"}}, false},
+	}
+
+	for _, s := range Searchers() {
+		for _, c := range cases {
+			t.Run(s.Name()+"/"+c.name, func(t *testing.T) {
+				result, err := Run(s, SearchRequest{
+					Target:      "Airplane",
+					MaxRecipes:  3,
+					Constraints: c.constraints,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(result.Trees) == 0 {
+					t.Fatal("no tree passes the constraint")
+				}
+				for i, tree := range result.Trees {
+					if treeHas(tree, "Steel") != c.want {
+						t.Errorf("tree %d breaks the %s constraint", i, c.name)
+					}
+				}
+			})
+		}
+	}
+}
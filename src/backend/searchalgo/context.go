@@ -0,0 +1,175 @@
+package searchalgo
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"runtime"
+	"sync/atomic"
+	"tubes2/utilities"
+)
+
+// used by the api and the legacy entry points when a request does not say.
+// parallel searches then keep the first trees any worker finishes. while it is
+// set every search is deterministic, which the test mains turn on
+var DefaultDeterministic = false
+
+// per-search state shared by the algorithms
+type searchContext struct {
+	req      SearchRequest
+	excluded map[string]bool
+
+	// recipes and trees cut by the constraints, shared with every branch
+	pruned *atomic.Int64
+	// recipes skipped in relaxed mode because they lead back to an ancestor
+	cycles *atomic.Int64
+
+	budget *budgetTracker
+	stats  *statsRecorder
+	trace  *Tracer
+}
+
+func newSearchContext(req SearchRequest) *searchContext {
+	if DefaultDeterministic {
+		req.Deterministic = true
+	}
+	ctx := &searchContext{
+		req:      req,
+		excluded: make(map[string]bool),
+		pruned:   new(atomic.Int64),
+		cycles:   new(atomic.Int64),
+		budget:   newBudgetTracker(req.Budget),
+		stats:    newStatsRecorder(),
+		trace:    req.Trace,
+	}
+	for _, elem := range req.Constraints.Exclude {
+		ctx.excluded[elem] = true
+	}
+	return ctx
+}
+
+// starts over the budgets, counts and trace of ctx, so a cursor reports the
+// work of every page on its own
+func (ctx *searchContext) renew(trace *Tracer) {
+	ctx.pruned = new(atomic.Int64)
+	ctx.cycles = new(atomic.Int64)
+	ctx.budget = newBudgetTracker(ctx.req.Budget)
+	ctx.stats = newStatsRecorder()
+	ctx.trace = trace
+}
+
+// a context for one branch of a parallel search. it shares everything with
+// ctx except the tracer, which buffers the events of the branch until join
+// adds them to ctx in a fixed order
+func (ctx *searchContext) branch() *searchContext {
+	branch := *ctx
+	branch.trace = ctx.trace.fork()
+	return &branch
+}
+
+// adds the events of finished branches to the trace of ctx, in the order given
+func (ctx *searchContext) join(branches ...*searchContext) {
+	for _, branch := range branches {
+		ctx.trace.join(branch.trace)
+	}
+}
+
+// counts an expanded node in the stats and against the budget, false means
+// the search should stop and return what it has
+func (ctx *searchContext) expand(element string, depth int) bool {
+	return ctx.expandFrom("", element, depth)
+}
+
+// expand for the bidirectional search, which traces the side it came from
+func (ctx *searchContext) expandFrom(direction string, element string, depth int) bool {
+	if !ctx.budget.spend() {
+		return false
+	}
+	ctx.stats.expanded()
+	ctx.trace.record(TraceEvent{Kind: TraceVisit, Element: element, Depth: depth, Direction: direction})
+	return true
+}
+
+// records the expansion of the target, which the budget does not limit
+func (ctx *searchContext) visit(element string, depth int) {
+	ctx.stats.expanded()
+	ctx.trace.record(TraceEvent{Kind: TraceVisit, Element: element, Depth: depth})
+}
+
+// records a node put on the frontier
+func (ctx *searchContext) generate(element string, depth int) {
+	ctx.generateFrom("", element, depth)
+}
+
+func (ctx *searchContext) generateFrom(direction string, element string, depth int) {
+	ctx.stats.generated(depth)
+	ctx.trace.record(TraceEvent{Kind: TraceEnqueue, Element: element, Depth: depth, Direction: direction})
+}
+
+// number of workers a parallel search may run at once
+func (ctx *searchContext) parallelism() int {
+	if ctx.req.Parallelism > 0 {
+		return ctx.req.Parallelism
+	}
+	return runtime.NumCPU()
+}
+
+// returns the recipes of an element in the order the search should try them.
+// without a seed this is the dataset order, with a seed the order is shuffled
+// per element so the tie-breaking does not depend on goroutine scheduling
+func (ctx *searchContext) recipesFor(element string) []utilities.Recipe {
+	recipes := utilities.Recipes[element]
+	if ctx.req.Seed == 0 || len(recipes) < 2 {
+		return recipes
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(element))
+	rng := rand.New(rand.NewSource(ctx.req.Seed ^ int64(h.Sum64())))
+
+	shuffled := append([]utilities.Recipe(nil), recipes...)
+	rng.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	return shuffled
+}
+
+// wraps the trees of a finished search together with its metrics
+func (ctx *searchContext) result(trees []utilities.RecipeTree, visited int, steps []utilities.Step) SearchResult {
+	return SearchResult{
+		Trees: trees,
+		Steps: steps,
+		Metrics: Metrics{
+			NodesVisited: visited,
+			Pruned:       int(ctx.pruned.Load()),
+			Cycles:       int(ctx.cycles.Load()),
+			BudgetHit:    ctx.budget.hit.Load(),
+			Stats:        ctx.stats.snapshot(),
+		},
+	}
+}
+
+// recipes of an element whose ingredients both have a lower tier and pass the
+// constraints, in the order the search should try them. recipes that only
+// differ by ingredient order are returned once
+func (ctx *searchContext) tierValidRecipes(element string) []utilities.Recipe {
+	elementTier, ok := utilities.Tiers[element]
+	if !ok {
+		return nil
+	}
+
+	var valid []utilities.Recipe
+	recipes := tierValidRecipes(ctx.recipesFor(element), elementTier, func(recipe utilities.Recipe, repeat bool) {
+		if repeat {
+			ctx.stats.duplicate()
+			return
+		}
+		ctx.stats.tierPruned()
+		ctx.trace.prune(element, &recipe, PruneTier)
+	})
+	for _, recipe := range recipes {
+		if ctx.allowsRecipe(recipe) {
+			valid = append(valid, recipe)
+		}
+	}
+	return valid
+}
@@ -0,0 +1,76 @@
+package searchalgo
+
+import (
+	"tubes2/utilities"
+)
+
+const unreachableCost = -1
+
+// minimal number of crafting steps per element when the owned elements and the
+// base elements are free. only tier-valid recipes are used, so the recipe graph
+// stays acyclic and every element is solved once
+type costTable struct {
+	owned  map[string]bool
+	cost   map[string]int
+	choice map[string]utilities.Recipe
+}
+
+func newCostTable(owned map[string]bool) *costTable {
+	return &costTable{
+		owned:  owned,
+		cost:   make(map[string]int),
+		choice: make(map[string]utilities.Recipe),
+	}
+}
+
+func (t *costTable) costOf(element string) int {
+	if t.owned[element] || utilities.IsBaseElement(element) {
+		return 0
+	}
+	if c, ok := t.cost[element]; ok {
+		return c
+	}
+
+	best := unreachableCost
+	if elementTier, ok := utilities.Tiers[element]; ok {
+		for _, recipe := range tierValidRecipes(utilities.Recipes[element], elementTier, nil) {
+			c1 := t.costOf(recipe.Element1)
+			c2 := t.costOf(recipe.Element2)
+			if c1 == unreachableCost || c2 == unreachableCost {
+				continue
+			}
+			if c := 1 + c1 + c2; best == unreachableCost || c < best {
+				best = c
+				t.choice[element] = recipe
+			}
+		}
+	}
+
+	t.cost[element] = best
+	return best
+}
+
+func (t *costTable) tree(element string) utilities.RecipeTree {
+	tree := utilities.RecipeTree{Element: element}
+	if t.owned[element] || utilities.IsBaseElement(element) {
+		return tree
+	}
+
+	recipe := t.choice[element]
+	tree.Ingredients = []utilities.RecipeTree{
+		t.tree(recipe.Element1),
+		t.tree(recipe.Element2),
+	}
+	return tree
+}
+
+// returns the tree with the fewest crafting steps for the target whose leaves
+// are owned or base elements, and false when the target cannot be made
+func CheapestTree(target string, owned map[string]bool) (utilities.RecipeTree, int, bool) {
+	table := newCostTable(owned)
+	steps := table.costOf(target)
+	if steps == unreachableCost {
+		return utilities.RecipeTree{}, 0, false
+	}
+	return table.tree(target), steps, true
+}
@@ -0,0 +1,104 @@
+package searchalgo
+
+import (
+	"math/big"
+	"sync"
+	"tubes2/utilities"
+)
+
+// memoized tree counts, filled lazily from the lowest tier upwards
+var (
+	treeCounts     = make(map[string]*big.Int)
+	treeCountMutex sync.Mutex
+)
+
+func init() {
+	utilities.OnDatasetChange(func() {
+		treeCountMutex.Lock()
+		treeCounts = make(map[string]*big.Int)
+		treeCountMutex.Unlock()
+	})
+}
+
+// counts the distinct tier-valid recipe trees for an element without enumerating them
+func CountRecipeTrees(element string) *big.Int {
+	total, _ := lockedRecipeCounts(element, nil)
+	return new(big.Int).Set(total)
+}
+
+// the trees of element and the trees rooted at each of recipes. memoized counts
+// are never changed, so they are read after the lock is released
+func lockedRecipeCounts(element string, recipes []utilities.Recipe) (*big.Int, []*big.Int) {
+	treeCountMutex.Lock()
+	defer treeCountMutex.Unlock()
+
+	weights := make([]*big.Int, len(recipes))
+	for i, recipe := range recipes {
+		weights[i] = countRecipe(recipe)
+	}
+	return countTrees(element), weights
+}
+
+// the caller holds treeCountMutex
+func countTrees(element string) *big.Int {
+	if count, ok := treeCounts[element]; ok {
+		return count
+	}
+
+	count := new(big.Int)
+	if utilities.IsBaseElement(element) {
+		count.SetInt64(1)
+	} else if elementTier, ok := utilities.Tiers[element]; ok {
+		for _, recipe := range tierValidRecipes(utilities.Recipes[element], elementTier, nil) {
+			count.Add(count, countRecipe(recipe))
+		}
+	}
+
+	treeCounts[element] = count
+	return count
+}
+
+// trees that use recipe at their root
+func countRecipe(recipe utilities.Recipe) *big.Int {
+	c1 := countTrees(recipe.Element1)
+	if recipe.Element1 == recipe.Element2 {
+		// both sides may be swapped, so only unordered pairs of subtrees are distinct
+		pairs := new(big.Int).Add(c1, big.NewInt(1))
+		pairs.Mul(pairs, c1)
+		pairs.Rsh(pairs, 1)
+		return pairs
+	}
+	c2 := countTrees(recipe.Element2)
+	return new(big.Int).Mul(c1, c2)
+}
+
+// returns the recipes of an element of elementTier whose ingredients both have
+// a lower tier, skipping recipes that only differ by ingredient order. skipped,
+// when set, is told about every recipe left out and whether it was a repeat
+func tierValidRecipes(recipes []utilities.Recipe, elementTier int, skipped func(recipe utilities.Recipe, repeat bool)) []utilities.Recipe {
+	var valid []utilities.Recipe
+	seen := make(map[[2]string]bool)
+
+	for _, recipe := range recipes {
+		e1Tier, ok1 := utilities.Tiers[recipe.Element1]
+		e2Tier, ok2 := utilities.Tiers[recipe.Element2]
+		if !ok1 || !ok2 || e1Tier >= elementTier || e2Tier >= elementTier {
+			if skipped != nil {
+				skipped(recipe, false)
+			}
+			continue
+		}
+
+		key := utilities.PairKey(recipe.Element1, recipe.Element2)
+		if seen[key] {
+			if skipped != nil {
+				skipped(recipe, true)
+			}
+			continue
+		}
+		seen[key] = true
+		valid = append(valid, recipe)
+	}
+
+	return valid
+}
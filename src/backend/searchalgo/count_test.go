@@ -0,0 +1,77 @@
+package searchalgo
+
+import (
+	"math/big"
+	"testing"
+	"tubes2/utilities"
+)
+
+// elements with more trees than this are not enumerated by the brute force
+const bruteForceLimit = 5000
+
+// every distinct tier-valid tree of element written out, nil once there are
+// more than bruteForceLimit of them. the ingredients of a node are sorted so
+// trees that only swap two subtrees are the same string
+func bruteForceTrees(element string, memo map[string][]string) []string {
+	if trees, ok := memo[element]; ok {
+		return trees
+	}
+	if utilities.IsBaseElement(element) {
+		memo[element] = []string{element}
+		return memo[element]
+	}
+
+	tier := utilities.Tiers[element]
+	seen := make(map[string]bool)
+	var trees []string
+	for _, recipe := range utilities.Recipes[element] {
+		t1, ok1 := utilities.Tiers[recipe.Element1]
+		t2, ok2 := utilities.Tiers[recipe.Element2]
+		if !ok1 || !ok2 || t1 >= tier || t2 >= tier {
+			continue
+		}
+		left := bruteForceTrees(recipe.Element1, memo)
+		right := bruteForceTrees(recipe.Element2, memo)
+		if left == nil || right == nil {
+			memo[element] = nil
+			return nil
+		}
+		for _, l := range left {
+			for _, r := range right {
+				a, b := l, r
+				if b < a {
+					a, b = b, a
+				}
+				tree := element + "(" + a + "," + b + ")"
+				if !seen[tree] {
+					seen[tree] = true
+					trees = append(trees, tree)
+				}
+				if len(trees) > bruteForceLimit {
+					memo[element] = nil
+					return nil
+				}
+			}
+		}
+	}
+	memo[element] = trees
+	return trees
+}
+
+func TestCountRecipeTreesMatchesBruteForce(t *testing.T) {
+	memo := make(map[string][]string)
+	checked := 0
+	for _, element := range utilities.ResultElements {
+		trees := bruteForceTrees(element, memo)
+		if trees == nil {
+			continue
+		}
+		checked++
+		if got := CountRecipeTrees(element); got.Cmp(big.NewInt(int64(len(trees)))) != 0 {
+			t.Errorf("%s: counted %s trees, enumerated %d", element, got, len(trees))
+		}
+	}
+	if checked < 20 {
+		t.Fatalf("only %d elements were small enough to enumerate", checked)
+	}
+}
@@ -0,0 +1,247 @@
+package searchalgo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"tubes2/utilities"
+)
+
+// how long an unused cursor stays valid, set from the command line
+var CursorTTL = 10 * time.Minute
+
+var (
+	ErrCursorUnknown = errors.New("unknown or expired cursor")
+	ErrCursorStale   = errors.New("cursor was issued for another recipe dataset")
+)
+
+// a position in an enumeration, after the trees handed out so far. the
+// enumeration itself waits in a goroutine that resumes for every page
+type cursorState struct {
+	searcher Searcher
+	req      SearchRequest
+	version  string
+	// context of the enumeration, renewed by every page
+	ctx *searchContext
+	// a page sends the number of trees it wants on more and reads them from
+	// batches. stop is closed once the cursor is dropped
+	more      chan int
+	batches   chan cursorBatch
+	stop      chan struct{}
+	exhausted bool
+	expires   time.Time
+	// a page is being served from this state
+	busy bool
+}
+
+// trees the enumeration found for one page
+type cursorBatch struct {
+	trees   []utilities.RecipeTree
+	visited int
+	// the enumeration has no more trees
+	done bool
+}
+
+var (
+	cursors     = make(map[string]*cursorState)
+	cursorMutex sync.Mutex
+)
+
+func init() {
+	utilities.OnDatasetChange(func() {
+		cursorMutex.Lock()
+		for token, state := range cursors {
+			state.drop(token)
+		}
+		cursors = make(map[string]*cursorState)
+		cursorMutex.Unlock()
+	})
+}
+
+// runs the first page of a paged enumeration. the cursor is empty once the
+// enumeration is known to have no more trees
+func RunPaged(s Searcher, req SearchRequest) (SearchResult, string, error) {
+	e, ok := s.(enumerator)
+	if !ok {
+		return SearchResult{}, "", fmt.Errorf("algorithm %q cannot be paged", s.Name())
+	}
+	if req.SortBy != "" || req.Diverse || len(req.Waypoints) > 0 {
+		return SearchResult{}, "", fmt.Errorf("paged searches keep the enumeration order and cannot be sorted, diversified or use waypoints")
+	}
+
+	trace := req.Trace
+	req.Deterministic = true
+	req.Trace = nil
+	req, err := prepare(s, req)
+	if err != nil {
+		return SearchResult{}, "", err
+	}
+
+	state := &cursorState{
+		searcher: s,
+		req:      req,
+		version:  utilities.DatasetVersion(),
+		ctx:      newSearchContext(req),
+		more:     make(chan int),
+		batches:  make(chan cursorBatch),
+		stop:     make(chan struct{}),
+	}
+	go state.enumerate(e)
+	return state.page(req.MaxRecipes, trace)
+}
+
+// hands out the next page of the enumeration behind token. the request names
+// the same algorithm and target as the first one, its recipe count is the size
+// of this page and zero keeps the size of the first. a request that does not
+// match leaves the cursor valid, the token is only used up once its page is
+// served
+func ContinueCursor(s Searcher, req SearchRequest, token string) (SearchResult, string, error) {
+	cursorMutex.Lock()
+	state, ok := cursors[token]
+	if !ok || time.Now().After(state.expires) {
+		if ok {
+			state.drop(token)
+		}
+		cursorMutex.Unlock()
+		return SearchResult{}, "", ErrCursorUnknown
+	}
+	if state.version != utilities.DatasetVersion() {
+		state.drop(token)
+		cursorMutex.Unlock()
+		return SearchResult{}, "", ErrCursorStale
+	}
+	if state.searcher.Name() != s.Name() || state.req.Target != req.Target {
+		cursorMutex.Unlock()
+		return SearchResult{}, "", fmt.Errorf("cursor belongs to a %s search for %q", state.searcher.Name(), state.req.Target)
+	}
+	if state.busy {
+		cursorMutex.Unlock()
+		return SearchResult{}, "", fmt.Errorf("cursor is already being paged by another request")
+	}
+	state.busy = true
+	cursorMutex.Unlock()
+
+	count := req.MaxRecipes
+	if count <= 0 {
+		count = state.req.MaxRecipes
+	}
+	result, next, err := state.page(count, req.Trace)
+
+	cursorMutex.Lock()
+	state.busy = false
+	if err == nil {
+		delete(cursors, token)
+	}
+	cursorMutex.Unlock()
+	return result, next, err
+}
+
+// runs the enumeration, handing its trees out in batches of the size each page
+// asks for. between pages it waits inside emit, so a page only pays for the
+// trees it hands out
+func (state *cursorState) enumerate(e enumerator) {
+	var want int
+	select {
+	case want = <-state.more:
+	case <-state.stop:
+		return
+	}
+
+	var batch cursorBatch
+	handed := 0
+	stopped := false
+	visited := e.enumerate(state.ctx, func(tree utilities.RecipeTree, visited int) bool {
+		batch.trees = append(batch.trees, tree)
+		if len(batch.trees) < want {
+			return true
+		}
+		batch.visited = visited - handed
+		handed = visited
+		state.batches <- batch
+		batch = cursorBatch{}
+
+		select {
+		case want = <-state.more:
+			return true
+		case <-state.stop:
+			stopped = true
+			return false
+		}
+	})
+	if stopped {
+		return
+	}
+	batch.visited = visited - handed
+	batch.done = true
+	state.batches <- batch
+}
+
+// serves the next count trees of the enumeration. the budgets of the first
+// request apply to every page on its own. the caller holds the busy flag of
+// the state
+func (state *cursorState) page(count int, trace *Tracer) (SearchResult, string, error) {
+	if state.exhausted {
+		return SearchResult{}, "", nil
+	}
+
+	// the enumeration is waiting, so the context can be renewed without locking
+	state.ctx.renew(trace)
+	select {
+	case state.more <- count:
+	case <-state.stop:
+		return SearchResult{}, "", ErrCursorUnknown
+	}
+	batch := <-state.batches
+
+	result := state.ctx.result(batch.trees, batch.visited, nil)
+	start := time.Now()
+	trees, scores, err := RankTrees(result.Trees, "", state.req.Costs)
+	if err != nil {
+		return SearchResult{}, "", err
+	}
+	result.Trees = trees
+	result.Scores = scores
+	result.Metrics.Stats.addPhase(PhaseRank, time.Since(start))
+
+	state.exhausted = batch.done
+	if state.exhausted {
+		return result, "", nil
+	}
+	token, err := state.store()
+	return result, token, err
+}
+
+// removes the cursor and ends its enumeration, the caller holds cursorMutex.
+// a state dropped while a page was served can be stored again by that page
+func (state *cursorState) drop(token string) {
+	delete(cursors, token)
+	select {
+	case <-state.stop:
+	default:
+		close(state.stop)
+	}
+}
+
+func (state *cursorState) store() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	now := time.Now()
+	state.expires = now.Add(CursorTTL)
+
+	cursorMutex.Lock()
+	defer cursorMutex.Unlock()
+	for key, other := range cursors {
+		if now.After(other.expires) && !other.busy {
+			other.drop(key)
+		}
+	}
+	cursors[token] = state
+	return token, nil
+}
@@ -0,0 +1,108 @@
+package searchalgo
+
+import (
+	"errors"
+	"testing"
+	"tubes2/utilities"
+)
+
+func TestCursorPagesFollowTheEnumeration(t *testing.T) {
+	dfs, _ := Lookup("dfs")
+	bfs, _ := Lookup("bfs")
+
+	full, err := Run(dfs, SearchRequest{Target: "Apron", MaxRecipes: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page, cursor, err := RunPaged(dfs, SearchRequest{Target: "Apron", MaxRecipes: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trees := page.Trees
+
+	// a request for another algorithm or target must not use the cursor up
+	if _, _, err := ContinueCursor(bfs, SearchRequest{Target: "Apron"}, cursor); err == nil {
+		t.Fatal("a bfs request continued a dfs cursor")
+	}
+	if _, _, err := ContinueCursor(dfs, SearchRequest{Target: "Brick"}, cursor); err == nil {
+		t.Fatal("a request for another target continued the cursor")
+	}
+
+	for len(trees) < len(full.Trees) && cursor != "" {
+		var next string
+		page, next, err = ContinueCursor(dfs, SearchRequest{Target: "Apron", MaxRecipes: 3}, cursor)
+		if err != nil {
+			t.Fatalf("after %d trees: %v", len(trees), err)
+		}
+		trees = append(trees, page.Trees...)
+
+		if _, _, err := ContinueCursor(dfs, SearchRequest{Target: "Apron"}, cursor); !errors.Is(err, ErrCursorUnknown) {
+			t.Fatalf("a served cursor was accepted again: %v", err)
+		}
+		cursor = next
+	}
+
+	if len(trees) < len(full.Trees) {
+		t.Fatalf("paging stopped after %d trees, the full search found %d", len(trees), len(full.Trees))
+	}
+	for i := range full.Trees {
+		if !utilities.IsSameRecipeTree(trees[i], full.Trees[i]) {
+			t.Errorf("paged tree %d differs from the full enumeration", i)
+		}
+	}
+}
+
+// a page continues where the one before it stopped, so two pages cost as much
+// as one page of both together
+func TestCursorPagesDoNotRevisitEarlierPages(t *testing.T) {
+	for _, name := range []string{"dfs", "bfs"} {
+		s, _ := Lookup(name)
+		t.Run(name, func(t *testing.T) {
+			whole, _, err := RunPaged(s, SearchRequest{Target: "Mailbox", MaxRecipes: 4})
+			if err != nil {
+				t.Fatal(err)
+			}
+			full, err := Run(s, SearchRequest{Target: "Mailbox", MaxRecipes: 4})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(whole.Trees) != len(full.Trees) {
+				t.Fatalf("a page of 4 has %d trees, the search found %d", len(whole.Trees), len(full.Trees))
+			}
+			for i := range full.Trees {
+				if !utilities.IsSameRecipeTree(whole.Trees[i], full.Trees[i]) {
+					t.Errorf("paged tree %d differs from the search", i)
+				}
+			}
+
+			first, cursor, err := RunPaged(s, SearchRequest{Target: "Mailbox", MaxRecipes: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cursor == "" {
+				t.Fatal("the first page left no cursor")
+			}
+			second, _, err := ContinueCursor(s, SearchRequest{Target: "Mailbox"}, cursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(first.Trees)+len(second.Trees) != len(whole.Trees) {
+				t.Fatalf("pages of %d and %d trees, one page of both has %d", len(first.Trees), len(second.Trees), len(whole.Trees))
+			}
+			paged := first.Metrics.Stats.Expansions + second.Metrics.Stats.Expansions
+			if paged != whole.Metrics.Stats.Expansions {
+				t.Errorf("the pages expanded %d + %d nodes, one page of both %d",
+					first.Metrics.Stats.Expansions, second.Metrics.Stats.Expansions, whole.Metrics.Stats.Expansions)
+			}
+			if second.Metrics.Stats.Expansions >= whole.Metrics.Stats.Expansions {
+				t.Errorf("the second page expanded %d nodes, as many as both pages together", second.Metrics.Stats.Expansions)
+			}
+			visited := first.Metrics.NodesVisited + second.Metrics.NodesVisited
+			if visited != whole.Metrics.NodesVisited {
+				t.Errorf("the pages visited %d nodes, one page of both %d", visited, whole.Metrics.NodesVisited)
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package searchalgo
 
 import (
-    "fmt"
     "sync"
     "tubes2/utilities"
 )
@@ -17,52 +16,96 @@ func (c *SafeCounter) Inc() {
 	c.mux.Unlock()
 }
 
+func (c *SafeCounter) Add(n int) {
+    c.mux.Lock()
+    c.v += n
+    c.mux.Unlock()
+}
+
 func (c *SafeCounter) Value() int {
     c.mux.Lock()
     defer c.mux.Unlock()
     return c.v
 }
 
+type dfsSearcher struct{}
+
+func init() {
+    Register(dfsSearcher{})
+}
+
+func (dfsSearcher) Name() string {
+    return "dfs"
+}
+
+func (dfsSearcher) Description() string {
+    return "Depth-first search that explores every ingredient combination recursively"
+}
+
+func (dfsSearcher) Options() []string {
+    return []string{"multipleRecipes", "recipeCount", "deterministic", "seed", "parallelism", "relaxed"}
+}
+
+func (dfsSearcher) Search(req SearchRequest) SearchResult {
+    return dfsSearch(newSearchContext(req))
+}
+
 func DFSSearch(target string, maxRecipes int) ([]utilities.RecipeTree, int) {
+    result := dfsSearch(newSearchContext(SearchRequest{
+        Target:        target,
+        MaxRecipes:    maxRecipes,
+        Deterministic: DefaultDeterministic,
+    }))
+    return result.Trees, result.Metrics.NodesVisited
+}
+
+// trees found from one top-level recipe of the target
+type dfsBranch struct {
+    ctx      *searchContext
+    explored bool
+    trees    []utilities.RecipeTree
+    counter  SafeCounter
+}
+
+func dfsSearch(ctx *searchContext) SearchResult {
+    target, maxRecipes := ctx.req.Target, ctx.req.MaxRecipes
     counter := &SafeCounter{v: 0}
     counter.Inc()
     if utilities.IsBaseElement(target) {
         tree := utilities.RecipeTree{Element: target}
-        return []utilities.RecipeTree{tree}, 0
+        return ctx.result([]utilities.RecipeTree{tree}, 0, nil)
     }
 
     if _, exists := utilities.Recipes[target]; !exists {
-        fmt.Printf("Target element '%s' doesn't exist or can't be created\n", target)
-        return nil, 0
+        return ctx.result(nil, 0, nil)
     }
 
+    ctx.generate(target, 0)
+    ctx.visit(target, 0)
+
     var mu sync.Mutex
     var allResults []utilities.RecipeTree
     
-    recipeList, _ := utilities.Recipes[target]
+    recipeList := ctx.recipesFor(target)
+    branches := make([]dfsBranch, len(recipeList))
     
     var wg sync.WaitGroup
     
-    sem := make(chan struct{}, 8) 
-    
-    fmt.Printf("Found %d direct recipes for '%s'\n", len(recipeList), target)
+    sem := make(chan struct{}, ctx.parallelism()) 
     
     for i, recipe := range recipeList {
-        if maxRecipes > 0 && len(allResults) >= maxRecipes {
+        mu.Lock()
+        full := !ctx.req.Deterministic && maxRecipes > 0 && len(allResults) >= maxRecipes
+        mu.Unlock()
+        if full || ctx.budget.exhausted() {
             break
         }
         
         e1 := recipe.Element1
         e2 := recipe.Element2
         
-        e1Tier, e1Exists := utilities.Tiers[e1]
-        e2Tier, e2Exists := utilities.Tiers[e2]
-        targetTier, targetExists := utilities.Tiers[target]
-        
-        if e1Exists && e2Exists && targetExists && 
-           (e1Tier >= targetTier || e2Tier >= targetTier) {
-            fmt.Printf("Skipping recipe #%d (%s + %s => %s) [tier violation]\n", 
-                i+1, e1, e2, target)
+        // every skipped recipe is counted and traced by the context
+        if !ctx.tierAllows(target, recipe) || ctx.closesCycle(nil, target, e1, e2) || !ctx.allowsRecipe(recipe) {
             continue
         }
 
@@ -78,101 +121,241 @@ func DFSSearch(target string, maxRecipes int) ([]utilities.RecipeTree, int) {
             e1 := rec.Element1
             e2 := rec.Element2
             
-            fmt.Printf("Exploring recipe #%d: %s + %s => %s\n", 
-                idx+1, e1, e2, target)
-            
-            var recipeCombinations []map[string][]string
-            
             baseMap := make(map[string][]string)
             baseMap[target] = []string{e1, e2}
 
-            ExploreAllCombinations(e1, e2, baseMap, &recipeCombinations, counter)
-            
-            validCount := 0
-            
-            for _, found := range recipeCombinations {
-                valid := true
-                for elem, ingredients := range found {
-                    if utilities.IsBaseElement(elem) {
-                        continue
-                    }
-                    for _, ing := range ingredients {
-                        if !utilities.IsBaseElement(ing) && found[ing] == nil {
-                            valid = false
-                            break
-                        }
-                    }
-                    if !valid {
-                        break
-                    }
+            // deterministic searches count every branch on its own so the
+            // totals can be merged in recipe order afterwards
+            branch := &branches[idx]
+            branch.explored = true
+            bctx := ctx.branch()
+            branch.ctx = bctx
+            branchCounter := counter
+            if ctx.req.Deterministic {
+                branchCounter = &branch.counter
+            }
+
+            traced := false
+            held := 0
+
+            // every combination is built as soon as it is complete, the time
+            // spent on it moves from the search phase to the build phase.
+            // the search stops once the branch holds maxRecipes trees or the
+            // budget runs out
+            stopSearch := bctx.stats.phase(PhaseSearch)
+            build := func(found map[string][]string) bool {
+                stopSearch()
+                defer func() {
+                    stopSearch = bctx.stats.phase(PhaseSearch)
+                }()
+                defer bctx.stats.phase(PhaseBuild)()
+
+                if !combinationComplete(found) {
+                    return true
+                }
+
+                recipeTree := utilities.BuildRecipeTree(target, found)
+                if !bctx.acceptsTree(recipeTree) {
+                    return true
+                }
+                if !traced {
+                    bctx.trace.found(rec, 0, "")
+                    traced = true
                 }
-                
-                if valid {
-                    validCount++
-                    recipeTree := utilities.BuildRecipeTree(target, found)
-                    
+
+                // the merge takes at most maxRecipes trees of a branch
+                if bctx.req.Deterministic {
+                    addUniqueTree(&branch.trees, recipeTree, maxRecipes)
+                    held = len(branch.trees)
+                } else {
                     mu.Lock()
-                    isUnique := true
-                    
-                    for _, existingTree := range allResults {
-                        if utilities.IsSameRecipeTree(recipeTree, existingTree) {
-                            isUnique = false
-                            break
-                        }
-                    }
-                    if isUnique && (maxRecipes <= 0 || len(allResults) < maxRecipes) {
-                        allResults = append(allResults, recipeTree)
-                        fmt.Printf("  Adding unique recipe #%d from combination #%d for %s\n", 
-                            len(allResults), validCount, target)
-                    }
+                    addUniqueTree(&allResults, recipeTree, maxRecipes)
+                    held = len(allResults)
                     mu.Unlock()
                 }
+                return maxRecipes <= 0 || held < maxRecipes
             }
-            
-            fmt.Printf("Recipe #%d (%s + %s => %s) exploration complete. Found %d valid combination(s)\n", 
-                idx+1, e1, e2, target, validCount)
-                
+            ExploreAllCombinations(bctx, e1, e2, baseMap, build, branchCounter)
+            stopSearch()
         }(i, recipe)
     }
     
     wg.Wait()
-    
-    fmt.Printf("All recipe explorations complete. Found %d unique recipe(s)\n", len(allResults))
-    return allResults, counter.Value()
+
+    if ctx.req.Deterministic {
+        for i := range branches {
+            if maxRecipes > 0 && len(allResults) >= maxRecipes {
+                break
+            }
+            if !branches[i].explored {
+                continue
+            }
+
+            counter.Add(branches[i].counter.Value())
+            ctx.join(branches[i].ctx)
+            for _, tree := range branches[i].trees {
+                addUniqueTree(&allResults, tree, maxRecipes)
+            }
+        }
+    } else {
+        for i := range branches {
+            if branches[i].explored {
+                ctx.join(branches[i].ctx)
+            }
+        }
+    }
+
+    return ctx.result(allResults, counter.Value(), nil)
 }
 
+// the order of a deterministic dfsSearch, one tree at a time. the top-level
+// recipes are explored one after another, so nothing past the last tree handed
+// out has been searched yet
+func (dfsSearcher) enumerate(ctx *searchContext, emit func(tree utilities.RecipeTree, visited int) bool) int {
+    target := ctx.req.Target
+    counter := &SafeCounter{v: 0}
+    counter.Inc()
+    if utilities.IsBaseElement(target) {
+        emit(utilities.RecipeTree{Element: target}, 0)
+        return 0
+    }
+
+    if _, exists := utilities.Recipes[target]; !exists {
+        return 0
+    }
+
+    ctx.generate(target, 0)
+    ctx.visit(target, 0)
+
+    var held []utilities.RecipeTree
+    stopped := false
+
+    for _, recipe := range ctx.recipesFor(target) {
+        if stopped || ctx.budget.exhausted() {
+            break
+        }
+
+        e1 := recipe.Element1
+        e2 := recipe.Element2
+        if !ctx.tierAllows(target, recipe) || ctx.closesCycle(nil, target, e1, e2) || !ctx.allowsRecipe(recipe) {
+            continue
+        }
+
+        baseMap := make(map[string][]string)
+        baseMap[target] = []string{e1, e2}
+        traced := false
+
+        // the time spent waiting for the next page is not part of any phase
+        stopSearch := ctx.stats.phase(PhaseSearch)
+        build := func(found map[string][]string) bool {
+            stopSearch()
+            if !combinationComplete(found) {
+                stopSearch = ctx.stats.phase(PhaseSearch)
+                return true
+            }
+
+            stopBuild := ctx.stats.phase(PhaseBuild)
+            recipeTree := utilities.BuildRecipeTree(target, found)
+            added := ctx.acceptsTree(recipeTree) && addUniqueTree(&held, recipeTree, 0)
+            stopBuild()
+            if added {
+                if !traced {
+                    ctx.trace.found(recipe, 0, "")
+                    traced = true
+                }
+                if !emit(recipeTree, counter.Value()) {
+                    stopped = true
+                    return false
+                }
+            }
+            stopSearch = ctx.stats.phase(PhaseSearch)
+            return true
+        }
+        ExploreAllCombinations(ctx, e1, e2, baseMap, build, counter)
+        if !stopped {
+            stopSearch()
+        }
+    }
 
-func ExploreAllCombinations(e1, e2 string, baseMap map[string][]string, results *[]map[string][]string, counter *SafeCounter) {
+    return counter.Value()
+}
+
+// whether every element of a combination that is not a base element has a recipe
+func combinationComplete(found map[string][]string) bool {
+    for elem, ingredients := range found {
+        if utilities.IsBaseElement(elem) {
+            continue
+        }
+        for _, ing := range ingredients {
+            if !utilities.IsBaseElement(ing) && found[ing] == nil {
+                return false
+            }
+        }
+    }
+    return true
+}
+
+// appends the tree unless an equivalent one is already present or the list is full
+func addUniqueTree(results *[]utilities.RecipeTree, tree utilities.RecipeTree, maxRecipes int) bool {
+    if maxRecipes > 0 && len(*results) >= maxRecipes {
+        return false
+    }
+    for _, existingTree := range *results {
+        if utilities.IsSameRecipeTree(tree, existingTree) {
+            return false
+        }
+    }
+    *results = append(*results, tree)
+    return true
+}
+
+
+// hands every complete combination of the two ingredients to emit, until emit
+// returns false
+func ExploreAllCombinations(ctx *searchContext, e1, e2 string, baseMap map[string][]string, emit func(map[string][]string) bool, counter *SafeCounter) {
     // counter.Inc()
     
-    e1Maps := ExploreElementRecipes(e1, utilities.CopyMap(baseMap), counter)
+    e1Maps := ExploreElementRecipes(ctx, e1, baseMap, 1, counter)
     
     for _, map1 := range e1Maps {
+        if !ctx.budget.check() {
+            return
+        }
 
-
-        e2Maps := ExploreElementRecipes(e2, utilities.CopyMap(map1), counter)
+        e2Maps := ExploreElementRecipes(ctx, e2, map1, 1, counter)
         
         for _, completeMap := range e2Maps {
-            *results = append(*results, completeMap)
+            if !emit(completeMap) {
+                return
+            }
         }
     }
 }
 
-func ExploreElementRecipes(element string, currentMap map[string][]string, counter *SafeCounter) []map[string][]string {
+// depth is the position of element in the recipe tree, the target is depth 0.
+// currentMap is never written, every recipe tried copies it first, so the
+// returned maps may share the ones passed in
+func ExploreElementRecipes(ctx *searchContext, element string, currentMap map[string][]string, depth int, counter *SafeCounter) []map[string][]string {
 
     counter.Inc()
+    ctx.generate(element, depth)
     if utilities.IsBaseElement(element) {
         return []map[string][]string{currentMap}
     }
     
     if _, ok := currentMap[element]; ok {
+        ctx.stats.duplicate()
         return []map[string][]string{currentMap}
     }
     
     // counter.Inc()
+    if !ctx.expand(element, depth) {
+        return nil
+    }
+    ctx.stats.frontier(depth)
     
-    recipeList, exists := utilities.Recipes[element]
-    if !exists {
+    recipeList := ctx.recipesFor(element)
+    if len(recipeList) == 0 {
         return nil 
     }
     
@@ -182,7 +365,10 @@ func ExploreElementRecipes(element string, currentMap map[string][]string, count
         e1 := recipe.Element1
         e2 := recipe.Element2
         
-        if utilities.Tiers[e1] >= utilities.Tiers[element] || utilities.Tiers[e2] >= utilities.Tiers[element] {
+        if !ctx.tierAllows(element, recipe) || ctx.closesCycle(currentMap, element, e1, e2) {
+            continue
+        }
+        if !ctx.allowsRecipe(recipe) {
             continue
         }
         
@@ -190,12 +376,19 @@ func ExploreElementRecipes(element string, currentMap map[string][]string, count
         newMap[element] = []string{e1, e2}
         
 
-        e1Maps := ExploreElementRecipes(e1, utilities.CopyMap(newMap), counter)
+        e1Maps := ExploreElementRecipes(ctx, e1, newMap, depth+1, counter)
         
+        completed := len(results)
         for _, map1 := range e1Maps {
-            e2Maps := ExploreElementRecipes(e2, utilities.CopyMap(map1), counter)
+            if !ctx.budget.check() {
+                return results
+            }
+            e2Maps := ExploreElementRecipes(ctx, e2, map1, depth+1, counter)
             results = append(results, e2Maps...)
         }
+        if len(results) > completed {
+            ctx.trace.found(recipe, depth, "")
+        }
     }
     
     return results
@@ -0,0 +1,97 @@
+package searchalgo
+
+import (
+	"tubes2/utilities"
+)
+
+// how many candidates per requested recipe a diverse search collects
+const DiversityPoolFactor = 4
+
+// the distinct crafting steps of a tree, ingredient order ignored
+func stepSet(tree utilities.RecipeTree) map[string]bool {
+	steps := make(map[string]bool)
+
+	var walk func(node utilities.RecipeTree)
+	walk = func(node utilities.RecipeTree) {
+		if len(node.Ingredients) != 2 {
+			return
+		}
+		e1, e2 := node.Ingredients[0].Element, node.Ingredients[1].Element
+		if e1 > e2 {
+			e1, e2 = e2, e1
+		}
+		steps[e1+" + "+e2+" => "+node.Element] = true
+
+		walk(node.Ingredients[0])
+		walk(node.Ingredients[1])
+	}
+	walk(tree)
+
+	return steps
+}
+
+// jaccard distance between two step sets, 0 for identical and 1 for disjoint
+func stepDistance(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for step := range a {
+		if b[step] {
+			shared++
+		}
+	}
+	union := len(a) + len(b) - shared
+	return 1 - float64(shared)/float64(union)
+}
+
+// picks k trees that are as different from each other as possible. the first
+// candidate is always kept, then every round adds the candidate whose nearest
+// selected tree is furthest away. ties go to the earlier candidate
+func SelectDiverse(candidates []utilities.RecipeTree, k int) []utilities.RecipeTree {
+	if k <= 0 || len(candidates) <= k {
+		return candidates
+	}
+
+	sets := make([]map[string]bool, len(candidates))
+	for i, tree := range candidates {
+		sets[i] = stepSet(tree)
+	}
+
+	selected := []int{0}
+	taken := make([]bool, len(candidates))
+	taken[0] = true
+
+	// distance from every candidate to its nearest selected tree
+	nearest := make([]float64, len(candidates))
+	for i := range candidates {
+		nearest[i] = stepDistance(sets[i], sets[0])
+	}
+
+	for len(selected) < k {
+		best := -1
+		for i := range candidates {
+			if taken[i] {
+				continue
+			}
+			if best == -1 || nearest[i] > nearest[best] {
+				best = i
+			}
+		}
+
+		selected = append(selected, best)
+		taken[best] = true
+		for i := range candidates {
+			if d := stepDistance(sets[i], sets[best]); d < nearest[i] {
+				nearest[i] = d
+			}
+		}
+	}
+
+	result := make([]utilities.RecipeTree, len(selected))
+	for i, idx := range selected {
+		result[i] = candidates[idx]
+	}
+	return result
+}
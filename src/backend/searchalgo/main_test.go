@@ -0,0 +1,47 @@
+package searchalgo
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"tubes2/utilities"
+)
+
+// the scraped recipe book is not checked in, the tests run against a small
+// slice of it that holds every tier-valid recipe of the targets they search
+const testRecipesPath = "../testdata/recipes.json"
+
+func TestMain(m *testing.M) {
+	utilities.LoadRecipes(testRecipesPath)
+	DefaultDeterministic = true
+	os.Exit(m.Run())
+}
+
+// loads the test book without its first recipe, which is another dataset, and
+// loads the test book again once the test is done
+func loadChangedRecipes(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		utilities.LoadRecipes(testRecipesPath)
+	})
+
+	data, err := os.ReadFile(testRecipesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recipes []utilities.Recipe
+	if err := json.Unmarshal(data, &recipes); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = json.Marshal(recipes[1:])
+	changed := filepath.Join(t.TempDir(), "recipes.json")
+	if err := os.WriteFile(changed, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	version := utilities.DatasetVersion()
+	utilities.LoadRecipes(changed)
+	if utilities.DatasetVersion() == version {
+		t.Fatal("the changed book kept the dataset version")
+	}
+}
@@ -0,0 +1,164 @@
+package searchalgo
+
+import (
+	"fmt"
+)
+
+// a search request option as /api/algorithms describes it. the names are the
+// json fields of the api search request
+type OptionDef struct {
+	Name string `json:"name"`
+	// json type of the field: boolean, integer, string, array or object
+	Type string `json:"type"`
+	// the request has to set it, there is no default
+	Required bool `json:"required,omitempty"`
+	// value used when the request leaves the option out
+	Default any `json:"default,omitempty"`
+	// values a string option or the items of an array option accept, empty
+	// when any value goes
+	Values      []string `json:"values,omitempty"`
+	Description string   `json:"description"`
+	// algorithms that support the option, only filled by OptionDefinitions
+	Algorithms []string `json:"algorithms,omitempty"`
+}
+
+// every option an algorithm or Run can list, built on each call so the
+// defaults follow the settings made from the command line
+func optionTable() []OptionDef {
+	return []OptionDef{
+		{
+			Name:        "multipleRecipes",
+			Type:        "boolean",
+			Default:     false,
+			Description: "Set by the frontend together with recipeCount, the recipe count alone decides how many trees are returned",
+		},
+		{
+			Name:        "recipeCount",
+			Type:        "integer",
+			Required:    true,
+			Description: "Most trees returned, at least 1",
+		},
+		{
+			Name:        "deterministic",
+			Type:        "boolean",
+			Default:     DefaultDeterministic,
+			Description: "Identical requests return identical trees, order and metrics",
+		},
+		{
+			Name:        "seed",
+			Type:        "integer",
+			Default:     0,
+			Description: "Shuffles the recipe order per element, the random algorithm draws a fresh seed for 0",
+		},
+		{
+			Name:        "parallelism",
+			Type:        "integer",
+			Default:     0,
+			Description: "Workers used by the parallel searches, 0 means one per cpu",
+		},
+		{
+			Name:        "relaxed",
+			Type:        "boolean",
+			Default:     false,
+			Description: "Allows ingredients that are not of a lower tier, as long as no element is needed to make itself",
+		},
+		{
+			Name:        "heuristic",
+			Type:        "string",
+			Default:     HeuristicTier,
+			Values:      []string{HeuristicTier, HeuristicCost},
+			Description: "Estimate of the crafts still needed below an element",
+		},
+		{
+			Name:        "waypoints",
+			Type:        "array",
+			Default:     []string{},
+			Description: fmt.Sprintf("Elements every tree has to pass through, at most %d", MaxWaypoints),
+		},
+		{
+			Name:        "budget",
+			Type:        "object",
+			Default:     ServerBudget,
+			Description: "Limits maxNodes, maxTimeMs and maxMemoryMB of the search, 0 leaves a limit to the server",
+		},
+		{
+			Name:        "streaming",
+			Type:        "boolean",
+			Default:     true,
+			Description: "Every better tree is sent as a server-sent event by /api/anytime while the search goes on",
+		},
+		{
+			Name:        "sortBy",
+			Type:        "string",
+			Default:     "",
+			Values:      []string{SortByDepth, SortBySteps, SortByIntermediates, SortByMaxTier, SortByCost},
+			Description: "Orders the returned trees, empty keeps the order they were found in",
+		},
+		{
+			Name:        "costs",
+			Type:        "object",
+			Default:     map[string]float64{},
+			Description: "Crafting cost per element used by the cost sort key",
+		},
+		{
+			Name:        "diverse",
+			Type:        "boolean",
+			Default:     false,
+			Description: "Returns the trees that differ the most out of a larger candidate pool",
+		},
+		{
+			Name:        "candidatePool",
+			Type:        "integer",
+			Default:     0,
+			Description: fmt.Sprintf("Size of the pool the diverse trees are picked from, 0 means %d times the recipe count", DiversityPoolFactor),
+		},
+		{
+			Name:        "constraints",
+			Type:        "object",
+			Default:     Constraints{},
+			Description: "Elements every tree has to use (require) or must not use (exclude), and the most depth, tier and steps (maxDepth, maxTier, maxSteps) a tree may have",
+		},
+	}
+}
+
+// the definitions of the options s supports, its own followed by CommonOptions.
+// the budget default is the one s searches with when the request sets none
+func OptionsOf(s Searcher) []OptionDef {
+	table := make(map[string]OptionDef)
+	for _, def := range optionTable() {
+		table[def.Name] = def
+	}
+
+	var defs []OptionDef
+	for _, name := range append(s.Options(), CommonOptions...) {
+		def, ok := table[name]
+		if !ok {
+			panic(fmt.Sprintf("searchalgo: algorithm %q lists the undefined option %q", s.Name(), name))
+		}
+		if d, ok := s.(budgetDefaulter); ok && name == "budget" {
+			def.Default = Budget{}.withDefaults(d.defaultBudget()).within(ServerBudget)
+		}
+		defs = append(defs, def)
+	}
+	return defs
+}
+
+// every option any algorithm supports, together with the algorithms that do in
+// the order of Searchers
+func OptionDefinitions() []OptionDef {
+	supported := make(map[string][]string)
+	for _, s := range Searchers() {
+		for _, def := range OptionsOf(s) {
+			supported[def.Name] = append(supported[def.Name], s.Name())
+		}
+	}
+
+	var defs []OptionDef
+	for _, def := range optionTable() {
+		if algorithms := supported[def.Name]; len(algorithms) > 0 {
+			def.Algorithms = algorithms
+			defs = append(defs, def)
+		}
+	}
+	return defs
+}
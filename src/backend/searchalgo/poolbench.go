@@ -0,0 +1,53 @@
+package searchalgo
+
+import (
+	"time"
+)
+
+// timing of the parallel bfs for one worker pool size
+type PoolBenchRow struct {
+	Workers  int     `json:"workers"`
+	Searches int     `json:"searches"`
+	Visited  int     `json:"visited"`
+	AvgTime  float64 `json:"avgTime"`
+	Speedup  float64 `json:"speedup"`
+}
+
+// runs the multi-recipe bfs on every target with each pool size and reports the
+// average time per search. the speedup is relative to the first pool size
+func CompareBFSPools(targets []string, poolSizes []int, maxRecipes int, rounds int) []PoolBenchRow {
+	if rounds <= 0 {
+		rounds = 1
+	}
+
+	var rows []PoolBenchRow
+	for _, workers := range poolSizes {
+		row := PoolBenchRow{Workers: workers}
+		var total time.Duration
+
+		for r := 0; r < rounds; r++ {
+			for _, target := range targets {
+				start := time.Now()
+				result := bfsSearch(newSearchContext(SearchRequest{
+					Target:      target,
+					MaxRecipes:  maxRecipes,
+					Parallelism: workers,
+				}))
+				total += time.Since(start)
+				row.Searches++
+				row.Visited += result.Metrics.NodesVisited
+			}
+		}
+
+		if row.Searches > 0 {
+			row.AvgTime = float64(total.Microseconds()) / 1000 / float64(row.Searches)
+		}
+		if len(rows) > 0 && row.AvgTime > 0 {
+			row.Speedup = rows[0].AvgTime / row.AvgTime
+		} else {
+			row.Speedup = 1
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
@@ -0,0 +1,272 @@
+package searchalgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"tubes2/utilities"
+)
+
+// where a precomputed result came from
+const (
+	SourceTable = "table"
+	SourceLive  = "live"
+)
+
+// name of the table file, written next to recipes.json
+const MinimalTableFile = "minimal.json"
+
+// cheapest recipe of one element when only the base elements are owned.
+// elements that cannot be made have no entry
+type MinimalEntry struct {
+	Element1 string
+	Element2 string
+	Steps    int
+}
+
+// minimal recipe of every element, built for one dataset version
+type MinimalTable struct {
+	Version string
+	Entries map[string]MinimalEntry
+}
+
+// on disk every element name is stored once and the recipes refer to it by
+// index as [element, ingredient 1, ingredient 2, steps]
+type minimalFile struct {
+	Version  string   `json:"version"`
+	Elements []string `json:"elements"`
+	Recipes  [][4]int `json:"recipes"`
+}
+
+// file the precomputed algorithm loads its table from on first use, building
+// and writing it when it is missing or stale. empty builds it in memory only
+var MinimalTableLocation string
+
+var (
+	minimalTable *MinimalTable
+	minimalMutex sync.RWMutex
+)
+
+func MinimalTablePath(recipesPath string) string {
+	return filepath.Join(filepath.Dir(recipesPath), MinimalTableFile)
+}
+
+// solves every element of the loaded recipes once, bottom up
+func BuildMinimalTable() *MinimalTable {
+	table := newCostTable(nil)
+	minimal := &MinimalTable{
+		Version: utilities.DatasetVersion(),
+		Entries: make(map[string]MinimalEntry),
+	}
+	for _, elem := range utilities.ResultElements {
+		if utilities.IsBaseElement(elem) || table.costOf(elem) == unreachableCost {
+			continue
+		}
+		recipe := table.choice[elem]
+		minimal.Entries[elem] = MinimalEntry{
+			Element1: recipe.Element1,
+			Element2: recipe.Element2,
+			Steps:    table.cost[elem],
+		}
+	}
+	return minimal
+}
+
+func (t *MinimalTable) Write(path string) error {
+	var names []string
+	index := make(map[string]int)
+	name := func(elem string) int {
+		if i, ok := index[elem]; ok {
+			return i
+		}
+		index[elem] = len(names)
+		names = append(names, elem)
+		return index[elem]
+	}
+
+	results := make([]string, 0, len(t.Entries))
+	for elem := range t.Entries {
+		results = append(results, elem)
+	}
+	sort.Strings(results)
+
+	file := minimalFile{Version: t.Version, Recipes: make([][4]int, 0, len(results))}
+	for _, elem := range results {
+		entry := t.Entries[elem]
+		file.Recipes = append(file.Recipes, [4]int{name(elem), name(entry.Element1), name(entry.Element2), entry.Steps})
+	}
+	file.Elements = names
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func LoadMinimalTable(path string) (*MinimalTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file minimalFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	t := &MinimalTable{Version: file.Version, Entries: make(map[string]MinimalEntry, len(file.Recipes))}
+	for _, r := range file.Recipes {
+		for _, i := range r[:3] {
+			if i < 0 || i >= len(file.Elements) {
+				return nil, fmt.Errorf("%s: element index %d out of range", path, i)
+			}
+		}
+		t.Entries[file.Elements[r[0]]] = MinimalEntry{
+			Element1: file.Elements[r[1]],
+			Element2: file.Elements[r[2]],
+			Steps:    r[3],
+		}
+	}
+	return t, nil
+}
+
+// the table used by the precomputed algorithm
+func SetMinimalTable(t *MinimalTable) {
+	minimalMutex.Lock()
+	defer minimalMutex.Unlock()
+	minimalTable = t
+}
+
+// the current table, or nil when there is none for the loaded dataset
+func freshMinimalTable() *MinimalTable {
+	minimalMutex.RLock()
+	defer minimalMutex.RUnlock()
+
+	if minimalTable == nil || minimalTable.Version != utilities.DatasetVersion() {
+		return nil
+	}
+	return minimalTable
+}
+
+// loads the table at path when it was built for the loaded dataset, otherwise
+// builds it. true when the file on disk was used
+func loadOrBuildMinimalTable(path string) (*MinimalTable, bool) {
+	t, err := LoadMinimalTable(path)
+	if err == nil && t.Version == utilities.DatasetVersion() {
+		return t, true
+	}
+	return BuildMinimalTable(), false
+}
+
+// loads the table at path, or builds and writes it when the file is missing or
+// was built for another dataset. the table is only used once it is on disk, a
+// failed write leaves the current one in place. true when the file was used
+func PrepareMinimalTable(path string) (bool, error) {
+	t, loaded := loadOrBuildMinimalTable(path)
+	if !loaded {
+		if err := t.Write(path); err != nil {
+			return false, err
+		}
+	}
+	SetMinimalTable(t)
+	return loaded, nil
+}
+
+// the table of the precomputed algorithm for the loaded dataset, loaded or
+// built the first time it is needed and again whenever the dataset changed.
+// a table that cannot be written to MinimalTableLocation is still used, the
+// file is only a shortcut for the next start
+func lazyMinimalTable() *MinimalTable {
+	if t := freshMinimalTable(); t != nil {
+		return t
+	}
+
+	// searches arriving meanwhile wait for this table instead of building their own
+	minimalMutex.Lock()
+	defer minimalMutex.Unlock()
+	if minimalTable != nil && minimalTable.Version == utilities.DatasetVersion() {
+		return minimalTable
+	}
+
+	if MinimalTableLocation == "" {
+		minimalTable = BuildMinimalTable()
+	} else {
+		t, loaded := loadOrBuildMinimalTable(MinimalTableLocation)
+		if !loaded {
+			if err := t.Write(MinimalTableLocation); err != nil {
+				log.Printf("Failed to write minimal recipe table: %v", err)
+			}
+		}
+		minimalTable = t
+	}
+
+	// the dataset may have changed again while the table was built
+	if minimalTable.Version != utilities.DatasetVersion() {
+		return nil
+	}
+	return minimalTable
+}
+
+type precomputedSearcher struct{}
+
+func init() {
+	Register(precomputedSearcher{})
+}
+
+func (precomputedSearcher) Name() string {
+	return "precomputed"
+}
+
+func (precomputedSearcher) Description() string {
+	return "Looks up the minimal recipe in a table precomputed for every element, rebuilt whenever the recipes change"
+}
+
+func (precomputedSearcher) Options() []string {
+	return []string{"recipeCount"}
+}
+
+// the table only holds one unconstrained tree per element, every other request
+// and a table the dataset changed under are answered by a live search
+func (precomputedSearcher) Search(req SearchRequest) SearchResult {
+	table := lazyMinimalTable()
+	if table == nil || req.MaxRecipes > 1 || !req.Constraints.empty() {
+		req.Heuristic = HeuristicCost
+		result := astarSearcher{}.Search(req)
+		result.Metrics.Source = SourceLive
+		return result
+	}
+
+	ctx := newSearchContext(req)
+	stopSearch := ctx.stats.phase(PhaseSearch)
+	var trees []utilities.RecipeTree
+	visited := 0
+	if _, ok := table.Entries[req.Target]; ok || utilities.IsBaseElement(req.Target) {
+		trees = append(trees, table.tree(ctx, req.Target, 0, &visited))
+		ctx.foundTree(trees[0])
+	}
+	stopSearch()
+
+	result := ctx.result(trees, visited, nil)
+	result.Metrics.Source = SourceTable
+	return result
+}
+
+// follows the table down from element, one lookup per node of the tree
+func (t *MinimalTable) tree(ctx *searchContext, element string, depth int, visited *int) utilities.RecipeTree {
+	ctx.visit(element, depth)
+	*visited++
+	tree := utilities.RecipeTree{Element: element}
+	entry, ok := t.Entries[element]
+	if !ok {
+		return tree
+	}
+	tree.Ingredients = []utilities.RecipeTree{
+		t.tree(ctx, entry.Element1, depth+1, visited),
+		t.tree(ctx, entry.Element2, depth+1, visited),
+	}
+	return tree
+}
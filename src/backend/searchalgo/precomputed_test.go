@@ -0,0 +1,38 @@
+package searchalgo
+
+import (
+	"testing"
+	"tubes2/utilities"
+)
+
+func TestPrecomputedTableFollowsTheDataset(t *testing.T) {
+	saved := MinimalTableLocation
+	MinimalTableLocation = ""
+	t.Cleanup(func() {
+		MinimalTableLocation = saved
+	})
+
+	precomputed, _ := Lookup("precomputed")
+	search := func() SearchResult {
+		result, err := Run(precomputed, SearchRequest{Target: "Airplane", MaxRecipes: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Trees) != 1 {
+			t.Fatalf("found %d trees", len(result.Trees))
+		}
+		return result
+	}
+
+	if source := search().Metrics.Source; source != SourceTable {
+		t.Fatalf("the first search was answered by the %s source", source)
+	}
+
+	loadChangedRecipes(t)
+	if source := search().Metrics.Source; source != SourceTable {
+		t.Errorf("after a dataset change the search was answered by the %s source", source)
+	}
+	if table := freshMinimalTable(); table == nil || table.Version != utilities.DatasetVersion() {
+		t.Error("the table was not rebuilt for the changed dataset")
+	}
+}
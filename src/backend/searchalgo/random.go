@@ -0,0 +1,139 @@
+package searchalgo
+
+import (
+	"math/big"
+	"math/rand"
+	"time"
+	"tubes2/utilities"
+)
+
+// draws per requested tree before the sampler gives up on finding distinct
+// trees that also pass the constraints
+const SampleAttemptsPerRecipe = 50
+
+type randomSearcher struct{}
+
+func init() {
+	Register(randomSearcher{})
+}
+
+func (randomSearcher) Name() string {
+	return "random"
+}
+
+func (randomSearcher) Description() string {
+	return "Samples recipe trees uniformly at random among every tier-valid tree of the target"
+}
+
+func (randomSearcher) Options() []string {
+	return []string{"multipleRecipes", "recipeCount", "deterministic", "seed"}
+}
+
+func (randomSearcher) Search(req SearchRequest) SearchResult {
+	ctx := newSearchContext(req)
+	seed := sampleSeed(ctx.req)
+	sampler := &treeSampler{ctx: ctx, rng: rand.New(rand.NewSource(seed))}
+
+	stopSearch := ctx.stats.phase(PhaseSearch)
+	trees := sampler.sample(req.Target, req.MaxRecipes)
+	stopSearch()
+
+	result := ctx.result(trees, sampler.visited, nil)
+	result.Metrics.Seed = seed
+	return result
+}
+
+// the seed of the request, or a fresh one that is reported back so a surprising
+// tree can be drawn again
+func sampleSeed(req SearchRequest) int64 {
+	if req.Seed != 0 {
+		return req.Seed
+	}
+	if req.Deterministic {
+		return 1
+	}
+	return time.Now().UnixNano()
+}
+
+// draws trees top-down. every recipe of an element is picked with a weight equal
+// to the number of trees it roots, which makes every tree equally likely
+type treeSampler struct {
+	ctx     *searchContext
+	rng     *rand.Rand
+	visited int
+}
+
+func (s *treeSampler) sample(target string, maxRecipes int) []utilities.RecipeTree {
+	if maxRecipes <= 0 {
+		maxRecipes = 1
+	}
+
+	total, _ := lockedRecipeCounts(target, nil)
+	if total.Sign() == 0 {
+		return nil
+	}
+	if total.IsInt64() && total.Int64() < int64(maxRecipes) {
+		maxRecipes = int(total.Int64())
+	}
+
+	var results []utilities.RecipeTree
+	for attempt := 0; attempt < SampleAttemptsPerRecipe*maxRecipes && len(results) < maxRecipes; attempt++ {
+		tree, ok := s.draw(target, 0)
+		if !ok {
+			break
+		}
+		// rejecting trees keeps the draw uniform among the ones that are left
+		if s.ctx.acceptsTree(tree) {
+			addUniqueTree(&results, tree, maxRecipes)
+		}
+	}
+
+	return results
+}
+
+// draws one tree for element, false once the budget runs out
+func (s *treeSampler) draw(element string, depth int) (utilities.RecipeTree, bool) {
+	s.ctx.generate(element, depth)
+	s.visited++
+	tree := utilities.RecipeTree{Element: element}
+	if utilities.IsBaseElement(element) {
+		return tree, true
+	}
+	if !s.ctx.expand(element, depth) {
+		return tree, false
+	}
+
+	recipes := tierValidRecipes(utilities.Recipes[element], utilities.Tiers[element], nil)
+	total, weights := lockedRecipeCounts(element, recipes)
+	pick := new(big.Int).Rand(s.rng, total)
+	var recipe utilities.Recipe
+	for i, r := range recipes {
+		if pick.Cmp(weights[i]) < 0 {
+			recipe = r
+			break
+		}
+		pick.Sub(pick, weights[i])
+	}
+
+	left, ok := s.draw(recipe.Element1, depth+1)
+	if !ok {
+		return tree, false
+	}
+	right := left
+	if recipe.Element1 != recipe.Element2 {
+		right, ok = s.draw(recipe.Element2, depth+1)
+	} else if same, _ := lockedRecipeCounts(recipe.Element1, nil); pick.Cmp(same) >= 0 {
+		// the first c of the c(c+1)/2 unordered pairs use the same subtree
+		// twice, the others are two different subtrees drawn by rejection
+		for ok && utilities.IsSameRecipeTree(left, right) {
+			right, ok = s.draw(recipe.Element2, depth+1)
+		}
+	}
+	if !ok {
+		return tree, false
+	}
+
+	tree.Ingredients = []utilities.RecipeTree{left, right}
+	s.ctx.trace.found(recipe, depth, "")
+	return tree, true
+}
@@ -0,0 +1,40 @@
+package searchalgo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRandomSeedRepeatsTheDraw(t *testing.T) {
+	random, _ := Lookup("random")
+	draw := func(seed int64) SearchResult {
+		result, err := Run(random, SearchRequest{Target: "Aurora", MaxRecipes: 5, Seed: seed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	first := draw(42)
+	if len(first.Trees) != 5 {
+		t.Fatalf("drew %d trees, want 5", len(first.Trees))
+	}
+	if first.Metrics.Seed != 42 {
+		t.Errorf("reported seed %d, want the requested 42", first.Metrics.Seed)
+	}
+	if again := draw(42); !reflect.DeepEqual(again.Trees, first.Trees) {
+		t.Error("the same seed drew other trees")
+	}
+
+	// outside the tests a request without a seed draws with a fresh one, which
+	// is reported so asking with it draws the same trees again
+	DefaultDeterministic = false
+	defer func() { DefaultDeterministic = true }()
+	fresh := draw(0)
+	if fresh.Metrics.Seed == 0 {
+		t.Fatal("no seed reported for a request without one")
+	}
+	if replay := draw(fresh.Metrics.Seed); !reflect.DeepEqual(replay.Trees, fresh.Trees) {
+		t.Error("the reported seed did not repeat the draw")
+	}
+}
@@ -0,0 +1,110 @@
+package searchalgo
+
+import (
+	"fmt"
+	"sort"
+	"tubes2/utilities"
+)
+
+const (
+	SortByDepth         = "depth"
+	SortBySteps         = "steps"
+	SortByIntermediates = "intermediates"
+	SortByMaxTier       = "maxTier"
+	SortByCost          = "cost"
+)
+
+// how simple a recipe tree is, lower is simpler for every field
+type TreeScores struct {
+	Depth         int     `json:"depth"`
+	Steps         int     `json:"steps"`
+	Intermediates int     `json:"intermediates"`
+	MaxTier       int     `json:"maxTier"`
+	Cost          float64 `json:"cost"`
+}
+
+func ValidSortKey(key string) bool {
+	switch key {
+	case "", SortByDepth, SortBySteps, SortByIntermediates, SortByMaxTier, SortByCost:
+		return true
+	}
+	return false
+}
+
+// scores a tree. every crafting step costs costs[result], or 1 when the
+// element has no custom cost
+func ScoreTree(tree utilities.RecipeTree, costs map[string]float64) TreeScores {
+	scores := TreeScores{Depth: utilities.CalculateTreeDepth(tree)}
+	intermediates := make(map[string]bool)
+
+	var walk func(node utilities.RecipeTree)
+	walk = func(node utilities.RecipeTree) {
+		if node.Element != tree.Element {
+			scores.MaxTier = utilities.Max(scores.MaxTier, utilities.Tiers[node.Element])
+		}
+		if len(node.Ingredients) == 0 {
+			return
+		}
+
+		scores.Steps++
+		if cost, ok := costs[node.Element]; ok {
+			scores.Cost += cost
+		} else {
+			scores.Cost++
+		}
+		if node.Element != tree.Element {
+			intermediates[node.Element] = true
+		}
+
+		for _, ing := range node.Ingredients {
+			walk(ing)
+		}
+	}
+	walk(tree)
+
+	scores.Intermediates = len(intermediates)
+	return scores
+}
+
+// scores the trees and, when a sort key is given, orders them from simplest to
+// most complex. trees with equal scores keep their discovery order
+func RankTrees(trees []utilities.RecipeTree, sortBy string, costs map[string]float64) ([]utilities.RecipeTree, []TreeScores, error) {
+	if !ValidSortKey(sortBy) {
+		return nil, nil, fmt.Errorf("unknown sort key %q", sortBy)
+	}
+
+	type ranked struct {
+		tree   utilities.RecipeTree
+		scores TreeScores
+	}
+	list := make([]ranked, len(trees))
+	for i, tree := range trees {
+		list[i] = ranked{tree: tree, scores: ScoreTree(tree, costs)}
+	}
+
+	if sortBy != "" {
+		sort.SliceStable(list, func(i, j int) bool {
+			a, b := list[i].scores, list[j].scores
+			switch sortBy {
+			case SortByDepth:
+				return a.Depth < b.Depth
+			case SortBySteps:
+				return a.Steps < b.Steps
+			case SortByIntermediates:
+				return a.Intermediates < b.Intermediates
+			case SortByMaxTier:
+				return a.MaxTier < b.MaxTier
+			default:
+				return a.Cost < b.Cost
+			}
+		})
+	}
+
+	sortedTrees := make([]utilities.RecipeTree, len(list))
+	scores := make([]TreeScores, len(list))
+	for i, r := range list {
+		sortedTrees[i] = r.tree
+		scores[i] = r.scores
+	}
+	return sortedTrees, scores, nil
+}
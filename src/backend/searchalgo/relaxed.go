@@ -0,0 +1,91 @@
+package searchalgo
+
+import (
+	"tubes2/utilities"
+)
+
+// whether a recipe may be used to make element. strict searches only take
+// ingredients of a lower tier, relaxed ones take any recipe and rely on the
+// cycle check instead
+func (ctx *searchContext) tierAllows(element string, recipe utilities.Recipe) bool {
+	if ctx.req.Relaxed {
+		return true
+	}
+	if !belowTier(element, recipe) {
+		ctx.stats.tierPruned()
+		ctx.trace.prune(element, &recipe, PruneTier)
+		return false
+	}
+	return true
+}
+
+func belowTier(element string, recipe utilities.Recipe) bool {
+	tier, ok := utilities.Tiers[element]
+	e1Tier, ok1 := utilities.Tiers[recipe.Element1]
+	e2Tier, ok2 := utilities.Tiers[recipe.Element2]
+	return ok && ok1 && ok2 && e1Tier < tier && e2Tier < tier
+}
+
+// reports whether making element from the ingredients would put element on its
+// own ancestor path. strict searches never get here since tiers only go down
+func (ctx *searchContext) closesCycle(found map[string][]string, element string, ingredients ...string) bool {
+	if !ctx.req.Relaxed {
+		return false
+	}
+	if createsCycle(found, element, ingredients...) {
+		ctx.cycles.Add(1)
+		if len(ingredients) == 2 {
+			ctx.trace.prune(element, &utilities.Recipe{Element1: ingredients[0], Element2: ingredients[1], Result: element}, PruneCycle)
+		}
+		return true
+	}
+	return false
+}
+
+// follows the recipes already chosen in found from the ingredients and
+// checks whether element is reached again
+func createsCycle(found map[string][]string, element string, ingredients ...string) bool {
+	seen := make(map[string]bool)
+	stack := append([]string{}, ingredients...)
+	for len(stack) > 0 {
+		elem := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if elem == element {
+			return true
+		}
+		if seen[elem] {
+			continue
+		}
+		seen[elem] = true
+		stack = append(stack, found[elem]...)
+	}
+	return false
+}
+
+// recipes used by a tree that break the tier rule, so a strict search would
+// never have returned it
+func RelaxedRecipes(tree utilities.RecipeTree) []utilities.Recipe {
+	var relaxed []utilities.Recipe
+	seen := make(map[utilities.Recipe]bool)
+
+	var walk func(node utilities.RecipeTree)
+	walk = func(node utilities.RecipeTree) {
+		if len(node.Ingredients) == 2 {
+			recipe := utilities.Recipe{
+				Element1: node.Ingredients[0].Element,
+				Element2: node.Ingredients[1].Element,
+				Result:   node.Element,
+			}
+			if !belowTier(node.Element, recipe) && !seen[recipe] {
+				seen[recipe] = true
+				relaxed = append(relaxed, recipe)
+			}
+		}
+		for _, ing := range node.Ingredients {
+			walk(ing)
+		}
+	}
+	walk(tree)
+
+	return relaxed
+}
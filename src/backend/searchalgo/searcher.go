@@ -0,0 +1,269 @@
+package searchalgo
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+	"tubes2/utilities"
+)
+
+type SearchRequest struct {
+	Target string
+	// most trees returned, at least 1. Run rejects anything lower, so no
+	// algorithm has to decide what zero means
+	MaxRecipes int
+	// elements every tree starts from. the searches only start from the base
+	// elements, so Run rejects any other set
+	StartElements []string
+
+	// identical requests give identical trees, order and metrics
+	Deterministic bool
+	// shuffles the recipe order per element, zero keeps the dataset order
+	Seed int64
+	// workers used by the parallel searches, zero means one per cpu
+	Parallelism int
+
+	// orders the returned trees, empty keeps the discovery order
+	SortBy string
+	// per element crafting cost used by the "cost" sort key
+	Costs map[string]float64
+
+	// picks MaxRecipes trees that differ as much as possible out of a
+	// larger candidate pool instead of the first ones found
+	Diverse bool
+	// size of that pool, defaults to DiversityPoolFactor * MaxRecipes
+	CandidatePool int
+
+	Constraints Constraints
+	// elements every returned tree has to pass through. they are added to the
+	// required elements and the trees are ranked by size unless sorted otherwise
+	Waypoints []string
+
+	// "tier" or "cost", used by the astar algorithm
+	Heuristic string
+
+	// allows recipes whose ingredients are not of a lower tier, as long as
+	// no element ends up on its own ancestor path
+	Relaxed bool
+
+	// limits for this search, tightened by ServerBudget in Run
+	Budget Budget
+
+	// records the exploration of this search when set
+	Trace *Tracer
+
+	// called by the anytime search with every better tree it finds, from the
+	// goroutine running the search
+	OnImprove func(Improvement)
+}
+
+type Metrics struct {
+	NodesVisited int `json:"nodesVisited"`
+	Candidates   int `json:"candidates,omitempty"`
+	Pruned       int `json:"pruned,omitempty"`
+	Cycles       int `json:"cycles,omitempty"`
+	// set when the search stopped early, the trees are the ones found until then
+	BudgetHit *BudgetHit `json:"budgetHit,omitempty"`
+	// seed the random sampler drew with, passing it back draws the same trees
+	Seed int64 `json:"seed,omitempty"`
+	// "table" or "live" for the precomputed algorithm
+	Source string `json:"source,omitempty"`
+	// filled by the anytime search
+	Anytime *AnytimeMetrics `json:"anytime,omitempty"`
+	// set by RunCached
+	Cache *CacheMetrics `json:"cache,omitempty"`
+	// counted the same way by every algorithm, unlike NodesVisited which each
+	// algorithm counts its own way and is kept for the existing clients
+	Stats Stats `json:"stats"`
+
+	// filled by the bidirectional search
+	Forward  *DirectionMetrics `json:"forward,omitempty"`
+	Backward *DirectionMetrics `json:"backward,omitempty"`
+}
+
+type SearchResult struct {
+	Trees   []utilities.RecipeTree
+	Scores  []TreeScores
+	Steps   []utilities.Step
+	Metrics Metrics
+}
+
+// options applied by Run to the results of every algorithm
+var CommonOptions = []string{"sortBy", "costs", "diverse", "candidatePool", "constraints"}
+
+// every search algorithm exposed through the api implements this. Search gets
+// requests Run has validated, MaxRecipes is always positive and the algorithm
+// returns at most that many trees
+type Searcher interface {
+	Name() string
+	Description() string
+	// names of the request options the algorithm reads, OptionsOf describes them
+	Options() []string
+	Search(req SearchRequest) SearchResult
+}
+
+// implemented by algorithms with limits of their own, checked by Run once the
+// shared options are validated
+type requestValidator interface {
+	validate(req SearchRequest) error
+}
+
+// implemented by algorithms that can hand out their deterministic order one
+// tree at a time, which lets a cursor continue it instead of searching again.
+// emit gets every tree with the nodes visited so far and returns false to stop,
+// enumerate returns the nodes visited in total
+type enumerator interface {
+	enumerate(ctx *searchContext, emit func(tree utilities.RecipeTree, visited int) bool) int
+}
+
+var (
+	searchers     = make(map[string]Searcher)
+	searcherMutex sync.RWMutex
+)
+
+// adds an algorithm to the registry, usually from an init function
+func Register(s Searcher) {
+	searcherMutex.Lock()
+	defer searcherMutex.Unlock()
+
+	if _, exists := searchers[s.Name()]; exists {
+		panic(fmt.Sprintf("searchalgo: algorithm %q registered twice", s.Name()))
+	}
+	searchers[s.Name()] = s
+}
+
+func Lookup(name string) (Searcher, bool) {
+	searcherMutex.RLock()
+	defer searcherMutex.RUnlock()
+
+	s, exists := searchers[name]
+	return s, exists
+}
+
+// returns every registered algorithm sorted by name
+func Searchers() []Searcher {
+	searcherMutex.RLock()
+	defer searcherMutex.RUnlock()
+
+	list := make([]Searcher, 0, len(searchers))
+	for _, s := range searchers {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name() < list[j].Name()
+	})
+	return list
+}
+
+// runs a search and applies the post-processing every algorithm shares
+func Run(s Searcher, req SearchRequest) (SearchResult, error) {
+	req, err := prepare(s, req)
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	wanted := req.MaxRecipes
+	if req.Diverse && wanted > 0 {
+		req.MaxRecipes = req.CandidatePool
+		if req.MaxRecipes < wanted {
+			req.MaxRecipes = DiversityPoolFactor * wanted
+		}
+	}
+
+	result := s.Search(req)
+	start := time.Now()
+
+	if req.Diverse && wanted > 0 {
+		result.Metrics.Candidates = len(result.Trees)
+		result.Trees = SelectDiverse(result.Trees, wanted)
+	}
+
+	trees, scores, err := RankTrees(result.Trees, req.SortBy, req.Costs)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	result.Trees = trees
+	result.Scores = scores
+	result.Metrics.Stats.addPhase(PhaseRank, time.Since(start))
+
+	return result, nil
+}
+
+// validates the shared options of a request and fills in the defaults Run and
+// the cursors search with
+func prepare(s Searcher, req SearchRequest) (SearchRequest, error) {
+	if !ValidSortKey(req.SortBy) {
+		return req, fmt.Errorf("unknown sort key %q", req.SortBy)
+	}
+	if req.Heuristic != "" && req.Heuristic != HeuristicTier && req.Heuristic != HeuristicCost {
+		return req, fmt.Errorf("unknown heuristic %q", req.Heuristic)
+	}
+	if req.MaxRecipes <= 0 {
+		return req, fmt.Errorf("recipe count must be at least 1, got %d", req.MaxRecipes)
+	}
+	if err := req.Budget.validate(); err != nil {
+		return req, err
+	}
+	if len(req.StartElements) > 0 && !sameElements(req.StartElements, utilities.BaseElements) {
+		return req, fmt.Errorf("searches can only start from the base elements %v", utilities.BaseElements)
+	}
+	if d, ok := s.(budgetDefaulter); ok {
+		req.Budget = req.Budget.withDefaults(d.defaultBudget())
+	}
+	req.Budget = req.Budget.within(ServerBudget)
+	if req.Relaxed && !containsString(s.Options(), "relaxed") {
+		return req, fmt.Errorf("algorithm %q does not support relaxed mode", s.Name())
+	}
+	if len(req.Waypoints) > 0 {
+		require := append([]string{}, req.Constraints.Require...)
+		for _, elem := range req.Waypoints {
+			if !containsString(require, elem) {
+				require = append(require, elem)
+			}
+		}
+		req.Constraints.Require = require
+		if req.SortBy == "" {
+			req.SortBy = SortBySteps
+		}
+	}
+	if err := req.Constraints.validate(); err != nil {
+		return req, err
+	}
+	if v, ok := s.(requestValidator); ok {
+		if err := v.validate(req); err != nil {
+			return req, err
+		}
+	}
+	for _, elem := range req.Constraints.Exclude {
+		if elem == req.Target {
+			return req, fmt.Errorf("target %q cannot be excluded", elem)
+		}
+	}
+
+	return req, nil
+}
+
+// whether both lists hold the same elements, in any order and with repeats
+func sameElements(a, b []string) bool {
+	for _, elem := range a {
+		if !containsString(b, elem) {
+			return false
+		}
+	}
+	for _, elem := range b {
+		if !containsString(a, elem) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
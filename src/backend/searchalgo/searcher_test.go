@@ -0,0 +1,123 @@
+package searchalgo
+
+import (
+	"reflect"
+	"testing"
+	"tubes2/utilities"
+)
+
+// the timings are the only part of a result allowed to change between runs
+func withoutTimings(m Metrics) Metrics {
+	m.Stats.Phases = nil
+	if m.Anytime != nil {
+		anytime := *m.Anytime
+		anytime.FirstMs, anytime.BestMs = 0, 0
+		m.Anytime = &anytime
+	}
+	return m
+}
+
+// the requests leave Deterministic out, TestMain makes it the default
+func TestDeterministicRequestsRepeat(t *testing.T) {
+	for _, s := range Searchers() {
+		s := s
+		t.Run(s.Name(), func(t *testing.T) {
+			for _, target := range []string{"Brick", "Airplane", "Mailbox", "Astronomer"} {
+				req := SearchRequest{
+					Target:      target,
+					MaxRecipes:  3,
+					Parallelism: 4,
+				}
+				first, err := Run(s, req)
+				if err != nil {
+					t.Fatalf("%s: %v", target, err)
+				}
+
+				for i := 0; i < 3; i++ {
+					again, err := Run(s, req)
+					if err != nil {
+						t.Fatalf("%s: %v", target, err)
+					}
+					if len(again.Trees) != len(first.Trees) {
+						t.Fatalf("%s: run %d found %d trees, the first run found %d", target, i+2, len(again.Trees), len(first.Trees))
+					}
+					for j := range first.Trees {
+						if !utilities.IsSameRecipeTree(again.Trees[j], first.Trees[j]) {
+							t.Errorf("%s: run %d gave a different tree %d", target, i+2, j)
+						}
+					}
+					if !reflect.DeepEqual(withoutTimings(again.Metrics), withoutTimings(first.Metrics)) {
+						t.Errorf("%s: run %d metrics %+v differ from %+v", target, i+2, withoutTimings(again.Metrics), withoutTimings(first.Metrics))
+					}
+				}
+			}
+		})
+	}
+}
+
+// no search starts anywhere else, so trees from other start elements would be
+// marked invalid by the same response
+func TestRunOnlyStartsFromTheBaseElements(t *testing.T) {
+	for _, s := range Searchers() {
+		for _, starts := range [][]string{nil, {"Fire", "Air", "Water", "Earth"}} {
+			if _, err := Run(s, SearchRequest{Target: "Brick", MaxRecipes: 1, StartElements: starts}); err != nil {
+				t.Errorf("%s rejected the start elements %v: %v", s.Name(), starts, err)
+			}
+		}
+		if _, err := Run(s, SearchRequest{Target: "Brick", MaxRecipes: 1, StartElements: []string{"Air", "Fire"}}); err == nil {
+			t.Errorf("%s accepted start elements that are not the base elements", s.Name())
+		}
+	}
+}
+
+func TestRecipeCountMeansTheSameForEveryAlgorithm(t *testing.T) {
+	for _, s := range Searchers() {
+		for _, count := range []int{0, -1} {
+			if _, err := Run(s, SearchRequest{Target: "Mailbox", MaxRecipes: count}); err == nil {
+				t.Errorf("%s accepted a recipe count of %d", s.Name(), count)
+			}
+		}
+		for _, count := range []int{1, 2, 5} {
+			result, err := Run(s, SearchRequest{Target: "Mailbox", MaxRecipes: count})
+			if err != nil {
+				t.Fatalf("%s: %v", s.Name(), err)
+			}
+			if len(result.Trees) == 0 || len(result.Trees) > count {
+				t.Errorf("%s returned %d trees for a recipe count of %d", s.Name(), len(result.Trees), count)
+			}
+		}
+	}
+}
+
+// the accepted values the api lists have to pass Run, and every option an
+// algorithm names has a definition
+func TestOptionDefinitionsMatchRun(t *testing.T) {
+	for _, def := range OptionDefinitions() {
+		if len(def.Algorithms) == 0 {
+			t.Errorf("option %s lists no algorithm", def.Name)
+		}
+		for _, value := range def.Values {
+			for _, name := range def.Algorithms {
+				s, _ := Lookup(name)
+				req := SearchRequest{Target: "Brick", MaxRecipes: 1}
+				switch def.Name {
+				case "sortBy":
+					req.SortBy = value
+				case "heuristic":
+					req.Heuristic = value
+				default:
+					t.Fatalf("no request field for the values of option %s", def.Name)
+				}
+				if _, err := Run(s, req); err != nil {
+					t.Errorf("%s rejected %s %q: %v", name, def.Name, value, err)
+				}
+			}
+		}
+	}
+
+	for _, s := range Searchers() {
+		if got, want := len(OptionsOf(s)), len(s.Options())+len(CommonOptions); got != want {
+			t.Errorf("%s has %d option definitions for %d options", s.Name(), got, want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package searchalgo
+
+import (
+	"sync"
+	"time"
+)
+
+// names of the phases a search reports, in the order they usually run
+const (
+	PhaseSetup  = "setup"
+	PhaseSearch = "search"
+	PhaseBuild  = "build"
+	PhaseRank   = "rank"
+)
+
+// search statistics with the same meaning for every algorithm. a node is one
+// element the search still has to make, at the depth of its recipe tree
+// position (the target is depth 0). the backward side of the bidirectional
+// search counts its depth up from the base elements instead
+type Stats struct {
+	// nodes whose recipes were looked at
+	Expansions int `json:"expansions"`
+	// nodes put on the frontier, including base elements that need no expansion
+	Generated int `json:"generated"`
+	// nodes and recipes dropped because an equal one was already found
+	DuplicatesPruned int `json:"duplicatesPruned"`
+	// recipes skipped because an ingredient is not of a lower tier than the result
+	TierPruned int `json:"tierPruned"`
+	// generated nodes per depth, index 0 is the target
+	FrontierByDepth []int `json:"frontierByDepth"`
+	// deepest depth a node was generated at
+	MaxDepth int `json:"maxDepth"`
+	// most nodes waiting to be expanded at one time. for the depth-first
+	// searches this is the deepest recursion
+	PeakFrontier int `json:"peakFrontier"`
+	// wall time per phase in ms, summed over workers for the parallel searches.
+	// phases do not overlap: setup, search, build for trees made after the
+	// search itself, rank for the sorting and diversity selection done by Run
+	Phases []PhaseTime `json:"phases"`
+}
+
+type PhaseTime struct {
+	Name string  `json:"name"`
+	Time float64 `json:"time"`
+}
+
+// collects the stats of one search. the parallel searches share it, so every
+// method locks
+type statsRecorder struct {
+	mu     sync.Mutex
+	stats  Stats
+	phases map[string]time.Duration
+}
+
+func newStatsRecorder() *statsRecorder {
+	return &statsRecorder{phases: make(map[string]time.Duration)}
+}
+
+func (r *statsRecorder) expanded() {
+	r.mu.Lock()
+	r.stats.Expansions++
+	r.mu.Unlock()
+}
+
+// counts a node put on the frontier at the given depth
+func (r *statsRecorder) generated(depth int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stats.Generated++
+	for len(r.stats.FrontierByDepth) <= depth {
+		r.stats.FrontierByDepth = append(r.stats.FrontierByDepth, 0)
+	}
+	r.stats.FrontierByDepth[depth]++
+	if depth > r.stats.MaxDepth {
+		r.stats.MaxDepth = depth
+	}
+}
+
+func (r *statsRecorder) duplicate() {
+	r.mu.Lock()
+	r.stats.DuplicatesPruned++
+	r.mu.Unlock()
+}
+
+func (r *statsRecorder) tierPruned() {
+	r.mu.Lock()
+	r.stats.TierPruned++
+	r.mu.Unlock()
+}
+
+// records the current number of nodes waiting to be expanded
+func (r *statsRecorder) frontier(size int) {
+	r.mu.Lock()
+	if size > r.stats.PeakFrontier {
+		r.stats.PeakFrontier = size
+	}
+	r.mu.Unlock()
+}
+
+// starts timing a phase, the returned function stops it. a phase that runs
+// more than once adds up
+func (r *statsRecorder) phase(name string) func() {
+	start := time.Now()
+	return func() {
+		elapsed := time.Since(start)
+		r.mu.Lock()
+		if _, seen := r.phases[name]; !seen {
+			r.stats.Phases = append(r.stats.Phases, PhaseTime{Name: name})
+		}
+		r.phases[name] += elapsed
+		r.mu.Unlock()
+	}
+}
+
+func (r *statsRecorder) snapshot() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := r.stats
+	stats.FrontierByDepth = append([]int{}, r.stats.FrontierByDepth...)
+	stats.Phases = make([]PhaseTime, len(r.stats.Phases))
+	for i, p := range r.stats.Phases {
+		stats.Phases[i] = PhaseTime{Name: p.Name, Time: milliseconds(r.phases[p.Name])}
+	}
+	return stats
+}
+
+// adds a phase timed outside the search itself, like the ranking done by Run
+func (s *Stats) addPhase(name string, d time.Duration) {
+	s.Phases = append(s.Phases, PhaseTime{Name: name, Time: milliseconds(d)})
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
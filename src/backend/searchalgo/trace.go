@@ -0,0 +1,158 @@
+package searchalgo
+
+import (
+	"sync"
+	"tubes2/utilities"
+)
+
+// kinds of trace events
+const (
+	// a node had its recipes looked at
+	TraceVisit = "visit"
+	// a node was put on the frontier to be visited later
+	TraceEnqueue = "enqueue"
+	// a recipe or a finished tree was cut, Reason says why
+	TracePrune = "prune"
+	// a recipe was picked for an element of a candidate tree
+	TraceFound = "found"
+)
+
+// reasons of prune events
+const (
+	PruneTier       = "tier"
+	PruneCycle      = "cycle"
+	PruneConstraint = "constraint"
+)
+
+// events kept per search when the caller does not pick a limit, the rest are
+// only counted
+const DefaultTraceLimit = 2000
+
+type TraceEvent struct {
+	Kind    string `json:"kind"`
+	Element string `json:"element"`
+	Depth   int    `json:"depth,omitempty"`
+	// "forward" or "backward" for the bidirectional search
+	Direction string            `json:"direction,omitempty"`
+	Recipe    *utilities.Recipe `json:"recipe,omitempty"`
+	Reason    string            `json:"reason,omitempty"`
+}
+
+// records what one search explored, in the order it happened. a search gets its
+// own tracer through SearchRequest.Trace, so concurrent searches never mix.
+// the goroutines of a parallel search record into forks that are joined in a
+// fixed order, so a deterministic search always gives the same events. a nil
+// tracer records nothing
+type Tracer struct {
+	mu      sync.Mutex
+	limit   int
+	events  []TraceEvent
+	dropped int
+}
+
+// zero or less keeps DefaultTraceLimit events
+func NewTracer(limit int) *Tracer {
+	if limit <= 0 {
+		limit = DefaultTraceLimit
+	}
+	return &Tracer{limit: limit}
+}
+
+func (t *Tracer) record(event TraceEvent) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.events) >= t.limit {
+		t.dropped++
+		return
+	}
+	t.events = append(t.events, event)
+}
+
+func (t *Tracer) Events() []TraceEvent {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]TraceEvent{}, t.events...)
+}
+
+// events that did not fit under the limit
+func (t *Tracer) Dropped() int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.dropped
+}
+
+// records the events of an earlier search, as far as the limit allows
+func (t *Tracer) replay(events []TraceEvent, dropped int) {
+	if t == nil {
+		return
+	}
+	for _, event := range events {
+		t.record(event)
+	}
+	t.mu.Lock()
+	t.dropped += dropped
+	t.mu.Unlock()
+}
+
+// an empty tracer with the same limit, buffering the events of one goroutine
+func (t *Tracer) fork() *Tracer {
+	if t == nil {
+		return nil
+	}
+	return &Tracer{limit: t.limit}
+}
+
+func (t *Tracer) join(child *Tracer) {
+	if t == nil || child == nil {
+		return
+	}
+	t.replay(child.Events(), child.Dropped())
+}
+
+func (t *Tracer) found(recipe utilities.Recipe, depth int, direction string) {
+	if t == nil {
+		return
+	}
+	t.record(TraceEvent{Kind: TraceFound, Element: recipe.Result, Depth: depth, Direction: direction, Recipe: &recipe})
+}
+
+func (t *Tracer) prune(element string, recipe *utilities.Recipe, reason string) {
+	if t == nil {
+		return
+	}
+	t.record(TraceEvent{Kind: TracePrune, Element: element, Recipe: recipe, Reason: reason})
+}
+
+// traces every recipe of a finished tree, root first, for the searches that
+// only know their recipes once a whole tree is complete
+func (ctx *searchContext) foundTree(tree utilities.RecipeTree) {
+	if ctx.trace == nil {
+		return
+	}
+
+	var walk func(node utilities.RecipeTree, depth int)
+	walk = func(node utilities.RecipeTree, depth int) {
+		if len(node.Ingredients) != 2 {
+			return
+		}
+		ctx.trace.found(utilities.Recipe{
+			Element1: node.Ingredients[0].Element,
+			Element2: node.Ingredients[1].Element,
+			Result:   node.Element,
+		}, depth, "")
+		for _, ing := range node.Ingredients {
+			walk(ing, depth+1)
+		}
+	}
+	walk(tree, 0)
+}
@@ -0,0 +1,40 @@
+package searchalgo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParallelTracesRepeat(t *testing.T) {
+	for _, name := range []string{"dfs", "bfs", "bidirectional"} {
+		s, _ := Lookup(name)
+		t.Run(name, func(t *testing.T) {
+			for _, target := range []string{"Mailbox", "Airplane", "Astronomer"} {
+				trace := func() ([]TraceEvent, int) {
+					tracer := NewTracer(100000)
+					_, err := Run(s, SearchRequest{
+						Target:      target,
+						MaxRecipes:  5,
+						Parallelism: 4,
+						Trace:       tracer,
+					})
+					if err != nil {
+						t.Fatalf("%s: %v", target, err)
+					}
+					return tracer.Events(), tracer.Dropped()
+				}
+
+				want, wantDropped := trace()
+				if len(want) == 0 {
+					t.Fatalf("%s: no trace events", target)
+				}
+				for i := 0; i < 5; i++ {
+					got, dropped := trace()
+					if !reflect.DeepEqual(got, want) || dropped != wantDropped {
+						t.Fatalf("%s: run %d traced %d events (%d dropped), the first run %d (%d dropped)", target, i+2, len(got), dropped, len(want), wantDropped)
+					}
+				}
+			}
+		})
+	}
+}
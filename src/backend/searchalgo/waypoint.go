@@ -0,0 +1,233 @@
+package searchalgo
+
+import (
+	"container/heap"
+	"fmt"
+	"tubes2/utilities"
+)
+
+// every waypoint doubles the number of states per element
+const MaxWaypoints = 8
+
+type waypointSearcher struct{}
+
+func init() {
+	Register(waypointSearcher{})
+}
+
+func (waypointSearcher) Name() string {
+	return "waypoint"
+}
+
+func (waypointSearcher) Description() string {
+	return "Finds the smallest recipe trees that pass through every waypoint element"
+}
+
+func (waypointSearcher) Options() []string {
+	return []string{"multipleRecipes", "recipeCount", "seed", "waypoints"}
+}
+
+// every waypoint doubles the states of the table, other algorithms check the
+// required elements per tree and need no cap
+func (waypointSearcher) validate(req SearchRequest) error {
+	if len(req.Constraints.Require) > MaxWaypoints {
+		return fmt.Errorf("at most %d waypoints can be required", MaxWaypoints)
+	}
+	return nil
+}
+
+func (waypointSearcher) Search(req SearchRequest) SearchResult {
+	ctx := newSearchContext(req)
+	w := newWaypointTable(ctx, req.Constraints.Require)
+	stopSearch := ctx.stats.phase(PhaseSearch)
+	trees := w.search(req.Target, req.MaxRecipes)
+	stopSearch()
+	return ctx.result(trees, w.states, nil)
+}
+
+type waypointState struct {
+	element string
+	need    uint
+}
+
+type waypointChoice struct {
+	recipe utilities.Recipe
+	split  uint
+}
+
+// dynamic programming over (element, waypoints still needed below it). the cost
+// is the number of crafting steps of the smallest tree for that element that
+// contains every needed waypoint. tier-valid recipes keep the states acyclic
+type waypointTable struct {
+	ctx    *searchContext
+	bits   map[string]uint
+	cost   map[waypointState]int
+	choice map[waypointState]waypointChoice
+	states int
+}
+
+func newWaypointTable(ctx *searchContext, waypoints []string) *waypointTable {
+	w := &waypointTable{
+		ctx:    ctx,
+		bits:   make(map[string]uint),
+		cost:   make(map[waypointState]int),
+		choice: make(map[waypointState]waypointChoice),
+	}
+	for _, elem := range waypoints {
+		if _, exists := w.bits[elem]; !exists {
+			w.bits[elem] = 1 << uint(len(w.bits))
+		}
+	}
+	return w
+}
+
+// depth is only used for the stats, a state reached again at another depth is
+// still answered from the table
+func (w *waypointTable) costOf(element string, need uint, depth int) int {
+	w.ctx.generate(element, depth)
+	need &^= w.bits[element]
+	if utilities.IsBaseElement(element) {
+		if need == 0 {
+			return 0
+		}
+		return unreachableCost
+	}
+
+	state := waypointState{element, need}
+	if c, ok := w.cost[state]; ok {
+		w.ctx.stats.duplicate()
+		return c
+	}
+	if !w.ctx.expand(element, depth) {
+		return unreachableCost
+	}
+	w.ctx.stats.frontier(depth)
+	w.states++
+
+	best := unreachableCost
+	for _, recipe := range w.ctx.tierValidRecipes(element) {
+		// every way of handing the needed waypoints to the two ingredients
+		for split := need; ; split = (split - 1) & need {
+			c1 := w.costOf(recipe.Element1, split, depth+1)
+			c2 := unreachableCost
+			if c1 != unreachableCost {
+				c2 = w.costOf(recipe.Element2, need^split, depth+1)
+			}
+			if c2 != unreachableCost && (best == unreachableCost || 1+c1+c2 < best) {
+				best = 1 + c1 + c2
+				w.choice[state] = waypointChoice{recipe: recipe, split: split}
+			}
+			if split == 0 {
+				break
+			}
+		}
+	}
+
+	if best != unreachableCost {
+		w.ctx.trace.found(w.choice[state].recipe, depth, "")
+	}
+	w.cost[state] = best
+	return best
+}
+
+func (w *waypointTable) tree(element string, need uint) utilities.RecipeTree {
+	need &^= w.bits[element]
+	tree := utilities.RecipeTree{Element: element}
+	if utilities.IsBaseElement(element) {
+		return tree
+	}
+
+	c := w.choice[waypointState{element, need}]
+	tree.Ingredients = []utilities.RecipeTree{
+		w.tree(c.recipe.Element1, c.split),
+		w.tree(c.recipe.Element2, need^c.split),
+	}
+	return tree
+}
+
+// enumerates the trees of the target from the fewest steps up. the table gives
+// the exact steps every pending (element, waypoints) pair still needs, so a
+// best-first search over partial trees finishes them in order of their steps,
+// and goes on until maxRecipes trees are found or no partial tree is left
+func (w *waypointTable) search(target string, maxRecipes int) []utilities.RecipeTree {
+	if utilities.IsBaseElement(target) {
+		if len(w.bits) == 0 || (len(w.bits) == 1 && w.bits[target] != 0) {
+			return []utilities.RecipeTree{{Element: target}}
+		}
+		return nil
+	}
+	if maxRecipes <= 0 {
+		maxRecipes = 1
+	}
+
+	w.ctx.visit(target, 0)
+	all := uint(1)<<uint(len(w.bits)) - 1
+	root := w.costOf(target, all, 0)
+	if root == unreachableCost {
+		return nil
+	}
+
+	queue := &astarQueue{}
+	seq := 0
+	heap.Push(queue, &astarNode{pending: []string{target}, depths: []int{0}, needs: []uint{all}, f: root})
+
+	var results []utilities.RecipeTree
+	for queue.Len() > 0 && len(results) < maxRecipes && w.ctx.budget.check() {
+		node := heap.Pop(queue).(*astarNode)
+		if len(node.pending) == 0 {
+			tree := rebuildAStarTree(target, node.choice)
+			if w.ctx.acceptsTree(tree) && addUniqueTree(&results, tree, maxRecipes) {
+				w.ctx.foundTree(tree)
+			} else {
+				w.ctx.stats.duplicate()
+			}
+			continue
+		}
+		w.states++
+
+		last := len(node.pending) - 1
+		element, depth := node.pending[last], node.depths[last]
+		need := node.needs[last] &^ w.bits[element]
+		restH := node.f - node.g - w.costOf(element, node.needs[last], depth)
+
+		for _, recipe := range w.ctx.tierValidRecipes(element) {
+			for split := need; ; split = (split - 1) & need {
+				c1 := w.costOf(recipe.Element1, split, depth+1)
+				c2 := unreachableCost
+				if c1 != unreachableCost {
+					c2 = w.costOf(recipe.Element2, need^split, depth+1)
+				}
+				if c2 != unreachableCost {
+					child := &astarNode{
+						pending: append([]string{}, node.pending[:last]...),
+						depths:  append([]int{}, node.depths[:last]...),
+						needs:   append([]uint{}, node.needs[:last]...),
+						choice:  &astarChoice{recipe: recipe, prev: node.choice},
+						g:       node.g + 1,
+						f:       node.g + 1 + restH + c1 + c2,
+					}
+					// the first ingredient ends on top so it is finished first,
+					// the pre-order rebuildAStarTree expects
+					ingredients := []string{recipe.Element2, recipe.Element1}
+					needs := []uint{need ^ split, split}
+					for i, ing := range ingredients {
+						if !utilities.IsBaseElement(ing) {
+							child.pending = append(child.pending, ing)
+							child.depths = append(child.depths, depth+1)
+							child.needs = append(child.needs, needs[i])
+						}
+					}
+					seq++
+					child.seq = seq
+					heap.Push(queue, child)
+				}
+				if split == 0 {
+					break
+				}
+			}
+		}
+		w.ctx.stats.frontier(queue.Len())
+	}
+
+	return results
+}
@@ -0,0 +1,41 @@
+package utilities
+
+import (
+	"sync"
+)
+
+var (
+	datasetVersion string
+	datasetHooks   []func()
+	datasetMutex   sync.Mutex
+)
+
+// identifies the loaded recipe book, it only changes when the content does
+func DatasetVersion() string {
+	datasetMutex.Lock()
+	defer datasetMutex.Unlock()
+	return datasetVersion
+}
+
+// registers a function that clears state derived from the recipe book. it runs
+// every time a book with a different version is loaded
+func OnDatasetChange(hook func()) {
+	datasetMutex.Lock()
+	defer datasetMutex.Unlock()
+	datasetHooks = append(datasetHooks, hook)
+}
+
+func setDatasetVersion(version string) {
+	datasetMutex.Lock()
+	if version == datasetVersion {
+		datasetMutex.Unlock()
+		return
+	}
+	datasetVersion = version
+	hooks := append([]func(){}, datasetHooks...)
+	datasetMutex.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+}
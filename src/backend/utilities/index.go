@@ -0,0 +1,46 @@
+package utilities
+
+// lookup tables built once when the recipes are loaded
+var (
+	// ingredient -> every recipe that uses it
+	UsesIndex = make(map[string][]Recipe)
+	// unordered ingredient pair -> every recipe combining exactly those two
+	CombinationIndex = make(map[[2]string][]Recipe)
+)
+
+// key for an ingredient pair that does not depend on the order of the two
+func PairKey(element1, element2 string) [2]string {
+	if element1 > element2 {
+		element1, element2 = element2, element1
+	}
+	return [2]string{element1, element2}
+}
+
+func buildIndexes(recipes []Recipe) {
+	for _, r := range recipes {
+		UsesIndex[r.Element1] = append(UsesIndex[r.Element1], r)
+		if r.Element2 != r.Element1 {
+			UsesIndex[r.Element2] = append(UsesIndex[r.Element2], r)
+		}
+
+		key := PairKey(r.Element1, r.Element2)
+		CombinationIndex[key] = append(CombinationIndex[key], r)
+	}
+}
+
+func FindUses(element string) []Recipe {
+	return UsesIndex[element]
+}
+
+func FindCombination(element1, element2 string) []Recipe {
+	return CombinationIndex[PairKey(element1, element2)]
+}
+
+func FindRecipe(element1, element2, result string) (Recipe, bool) {
+	for _, r := range FindCombination(element1, element2) {
+		if r.Result == result {
+			return r, true
+		}
+	}
+	return Recipe{}, false
+}
@@ -57,4 +57,7 @@ var (
 	Recipes      = make(map[string][]Recipe)
 	BaseElements = []string{"Water", "Fire", "Earth", "Air"}
 	Tiers        = make(map[string]int)
+
+	// every element that has at least one recipe, sorted so iteration order is stable
+	ResultElements []string
 )
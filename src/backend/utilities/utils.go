@@ -1,10 +1,12 @@
 package utilities
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
-	"sync"
+	"sort"
 )
 
 func IsBaseElement(element string) bool {
@@ -17,14 +19,25 @@ func IsBaseElement(element string) bool {
 }
 
 func BuildRecipeTree(element string, ingredients map[string][]string) RecipeTree {
+	return buildRecipeTree(element, ingredients, make(map[string]bool))
+}
+
+// an element that is already one of its own ancestors is left as a leaf, so a
+// cyclic ingredient map gives a tree the verifier rejects instead of endless recursion
+func buildRecipeTree(element string, ingredients map[string][]string, ancestors map[string]bool) RecipeTree {
 	tree := RecipeTree{Element: element}
-	
+	if ancestors[element] {
+		return tree
+	}
+
 	if ingList, exists := ingredients[element]; exists {
+		ancestors[element] = true
 		for _, ing := range ingList {
-			tree.Ingredients = append(tree.Ingredients, BuildRecipeTree(ing, ingredients))
+			tree.Ingredients = append(tree.Ingredients, buildRecipeTree(ing, ingredients, ancestors))
 		}
+		delete(ancestors, element)
 	}
-	
+
 	return tree
 }
 
@@ -118,12 +131,12 @@ func initializeTiers() {
         current := queue[0]
         queue = queue[1:]
         
-        for result, loadedRecipes := range Recipes {
+        for _, result := range ResultElements {
             if processed[result] {
                 continue 
             }
             
-            for _, recipe := range loadedRecipes {
+            for _, recipe := range Recipes[result] {
                 if (recipe.Element1 == current || recipe.Element2 == current) {
  
                     if tier1, ok1 := Tiers[recipe.Element1]; ok1 {
@@ -147,58 +160,66 @@ func initializeTiers() {
     }
 }
 
+// loads the recipe book, replacing whatever was loaded before. every change
+// of the book gets a new DatasetVersion and the registered caches are cleared.
+// not safe to call while searches are running
 func LoadRecipes(filePath string) {
-	file, err := os.Open(filePath)
+	data, err := os.ReadFile(filePath)
 	if err != nil {
 		fmt.Printf("Failed to open file: %v\n", err)
 		return
 	}
-	defer file.Close()
 
 	var loadedRecipes []Recipe
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&loadedRecipes); err != nil {
+	if err := json.Unmarshal(data, &loadedRecipes); err != nil {
 		fmt.Printf("Failed to decode JSON: %v\n", err)
 		return
 	}
 
+	Elements = make(map[string]Element)
+	Recipes = make(map[string][]Recipe)
+	Tiers = make(map[string]int)
+	ResultElements = nil
+	UsesIndex = make(map[string][]Recipe)
+	CombinationIndex = make(map[[2]string][]Recipe)
+
 	for _, r := range loadedRecipes {
+		if _, exists := Recipes[r.Result]; !exists {
+			ResultElements = append(ResultElements, r.Result)
+		}
 		Recipes[r.Result] = append(Recipes[r.Result], r)
 	}
+	sort.Strings(ResultElements)
+	buildIndexes(loadedRecipes)
 	
 	initializeTiers()
 
+	sum := sha256.Sum256(data)
+	setDatasetVersion(hex.EncodeToString(sum[:6]))
+
 	fmt.Printf("Loaded %d recipes.\n", len(loadedRecipes))
 }
 
-// tracking search progress
-var LiveUpdateCallback func(element string, path []string, found map[string][]string)
-var liveUpdateMutex sync.Mutex
-
-// sets callback function for live updates
-func SetLiveUpdateCallback(callback func(element string, path []string, found map[string][]string)) {
-    liveUpdateMutex.Lock()
-    defer liveUpdateMutex.Unlock()
-    LiveUpdateCallback = callback
+func FindIconForRecipe(element1, element2, result string) string {
+	if r, exists := FindRecipe(element1, element2, result); exists {
+		return r.IconFilename
+	}
+	return "unknown.png"
 }
-
-// calls the callback function for live updates
-func TrackLiveUpdate(element string, path []string, found map[string][]string) {
-	liveUpdateMutex.Lock()
-	defer liveUpdateMutex.Unlock()
-	if LiveUpdateCallback != nil {
-		LiveUpdateCallback(element, path, found)
+func ElementExists(element string) bool {
+	if IsBaseElement(element) {
+		return true
 	}
+	if _, exists := Recipes[element]; exists {
+		return true
+	}
+	_, used := UsesIndex[element]
+	return used
 }
 
-func FindIconForRecipe(element1, element2, result string) string {
-	if recipes, exists := Recipes[result]; exists {
-		for _, r := range recipes {
-			if (r.Element1 == element1 && r.Element2 == element2) ||
-			   (r.Element1 == element2 && r.Element2 == element1) {
-				return r.IconFilename
-			}
-		}
+func FindIconForElement(element string) string {
+	if recipes, exists := Recipes[element]; exists && len(recipes) > 0 {
+		return recipes[0].IconFilename
 	}
 	return "unknown.png"
-}
\ No newline at end of file
+}
@@ -0,0 +1,127 @@
+package verify
+
+import (
+	"fmt"
+	"strings"
+	"tubes2/utilities"
+)
+
+// what went wrong at one node of a tree, the path runs from the root down to the node
+type Issue struct {
+	Path    []string `json:"path"`
+	Element string   `json:"element"`
+	Problem string   `json:"problem"`
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s", strings.Join(i.Path, " > "), i.Problem)
+}
+
+type Report struct {
+	Valid  bool    `json:"valid"`
+	Issues []Issue `json:"issues,omitempty"`
+}
+
+// rules a tree is checked against. no start elements means the base elements.
+// a start element may be a leaf, one that is crafted anyway is checked like any
+// other node
+type Options struct {
+	StartElements []string
+	// trees from relaxed searches may use ingredients of any tier
+	Relaxed bool
+}
+
+// checks a recipe tree against the recipe book on its own, without trusting
+// anything the search algorithms computed
+func Tree(tree utilities.RecipeTree, opts Options) Report {
+	starts := opts.StartElements
+	if len(starts) == 0 {
+		starts = utilities.BaseElements
+	}
+
+	v := &verifier{
+		opts:   opts,
+		starts: make(map[string]bool),
+	}
+	for _, elem := range starts {
+		v.starts[elem] = true
+	}
+
+	v.walk(tree, nil)
+	return Report{Valid: len(v.issues) == 0, Issues: v.issues}
+}
+
+type verifier struct {
+	opts   Options
+	starts map[string]bool
+	issues []Issue
+}
+
+func (v *verifier) flag(path []string, element string, format string, args ...any) {
+	v.issues = append(v.issues, Issue{
+		Path:    append([]string{}, path...),
+		Element: element,
+		Problem: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *verifier) walk(node utilities.RecipeTree, ancestors []string) {
+	path := append(ancestors, node.Element)
+
+	for _, elem := range ancestors {
+		if elem == node.Element {
+			// going further would only repeat the same issues forever
+			v.flag(path, node.Element, "%s is needed to make itself", node.Element)
+			return
+		}
+	}
+
+	if len(node.Ingredients) == 0 {
+		if !v.starts[node.Element] {
+			v.flag(path, node.Element, "%s is not a start element and has no recipe", node.Element)
+		}
+		return
+	}
+
+	if len(node.Ingredients) != 2 {
+		v.flag(path, node.Element, "%s is made from %d ingredients instead of 2", node.Element, len(node.Ingredients))
+	} else {
+		e1, e2 := node.Ingredients[0].Element, node.Ingredients[1].Element
+		if !recipeExists(e1, e2, node.Element) {
+			v.flag(path, node.Element, "%s + %s does not make %s", e1, e2, node.Element)
+		}
+
+		// relaxed trees only have to be free of cycles
+		if !v.opts.Relaxed {
+			v.checkTiers(path, node.Element, e1, e2)
+		}
+	}
+
+	for _, ing := range node.Ingredients {
+		v.walk(ing, path)
+	}
+}
+
+func (v *verifier) checkTiers(path []string, element, e1, e2 string) {
+	tier, ok := utilities.Tiers[element]
+	if !ok {
+		v.flag(path, element, "%s has no tier", element)
+		return
+	}
+	for _, ing := range []string{e1, e2} {
+		if ingTier, ok := utilities.Tiers[ing]; ok && ingTier >= tier {
+			v.flag(path, element, "%s (tier %d) is not below %s (tier %d)", ing, ingTier, element, tier)
+		}
+	}
+}
+
+// looks the combination up in the raw recipe list instead of the indexes
+func recipeExists(element1, element2, result string) bool {
+	for _, recipe := range utilities.Recipes[result] {
+		if (recipe.Element1 == element1 && recipe.Element2 == element2) ||
+			(recipe.Element1 == element2 && recipe.Element2 == element1) {
+			return true
+		}
+	}
+	return false
+}
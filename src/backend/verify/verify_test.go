@@ -0,0 +1,82 @@
+package verify
+
+import (
+	"os"
+	"testing"
+	"tubes2/utilities"
+)
+
+func TestMain(m *testing.M) {
+	utilities.LoadRecipes("../testdata/recipes.json")
+	os.Exit(m.Run())
+}
+
+func leaf(element string) utilities.RecipeTree {
+	return utilities.RecipeTree{Element: element}
+}
+
+func node(element string, ingredients ...utilities.RecipeTree) utilities.RecipeTree {
+	return utilities.RecipeTree{Element: element, Ingredients: ingredients}
+}
+
+func TestTree(t *testing.T) {
+	mud := node("Mud", leaf("Water"), leaf("Earth"))
+
+	tests := []struct {
+		name string
+		tree utilities.RecipeTree
+		opts Options
+		// elements flagged, in the order of the issues
+		flagged []string
+	}{
+		{
+			name: "valid tree",
+			tree: node("Brick", mud, leaf("Fire")),
+		},
+		{
+			name:    "leaf that is not a start element",
+			tree:    node("Brick", leaf("Mud"), leaf("Fire")),
+			flagged: []string{"Mud"},
+		},
+		{
+			name:    "cycle",
+			tree:    node("Brick", node("Mud", node("Brick", mud, leaf("Fire")), leaf("Earth")), leaf("Fire")),
+			opts:    Options{Relaxed: true},
+			flagged: []string{"Mud", "Brick"},
+		},
+		{
+			name:    "three ingredients",
+			tree:    node("Brick", mud, leaf("Fire"), leaf("Air")),
+			flagged: []string{"Brick"},
+		},
+		{
+			name: "start element used as a leaf",
+			tree: node("Brick", leaf("Mud"), leaf("Fire")),
+			opts: Options{StartElements: []string{"Air", "Earth", "Fire", "Water", "Mud"}},
+		},
+		{
+			name: "expanded start element is checked",
+			tree: node("Brick", node("Mud", leaf("Fire"), leaf("Earth")), leaf("Fire")),
+			opts: Options{StartElements: []string{"Air", "Earth", "Fire", "Water", "Mud"}},
+			// Fire + Earth makes Lava, not Mud
+			flagged: []string{"Mud"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			report := Tree(tc.tree, tc.opts)
+			if report.Valid != (len(tc.flagged) == 0) {
+				t.Errorf("valid is %v with issues %v", report.Valid, report.Issues)
+			}
+			if len(report.Issues) != len(tc.flagged) {
+				t.Fatalf("got issues %v, want issues at %v", report.Issues, tc.flagged)
+			}
+			for i, issue := range report.Issues {
+				if issue.Element != tc.flagged[i] {
+					t.Errorf("issue %d is at %s, want %s: %v", i, issue.Element, tc.flagged[i], issue)
+				}
+			}
+		})
+	}
+}